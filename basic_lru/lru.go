@@ -2,22 +2,80 @@ package basic_lru
 
 import (
 	"fmt"
+	"iter"
 	"lru/internal"
+	"math"
+	"math/rand"
+	"time"
 )
 
+// maxWeightedFree is the number of removed entries a weighted LRU keeps for reuse,
+// as its size doesn't bound the number of entries.
+const maxWeightedFree = 64
+
 // EvictCallback is used to get a callback when a cache entry is evicted
 type EvictCallback[K comparable, V any] func(key K, value V)
 
+// ExportedEntry holds a cache entry with its metadata, as returned by Export.
+type ExportedEntry[K comparable, V any] struct {
+	Key         K
+	Value       V
+	CreatedAt   time.Time
+	ExpiresAt   time.Time // always zero as entries don't expire
+	AccessCount uint64
+}
+
+// Option configures optional behavior of the LRU.
+type Option[K comparable, V any] func(l *LRU[K, V])
+
+// WithOnEvict sets the callback invoked when an entry is evicted or removed.
+func WithOnEvict[K comparable, V any](onEvict EvictCallback[K, V]) Option[K, V] {
+	return func(l *LRU[K, V]) {
+		l.onEvict = onEvict
+	}
+}
+
+// WithOnAdd sets a callback invoked when a new key is added, by Add or the methods adding through it.
+func WithOnAdd[K comparable, V any](onAdd func(key K, value V)) Option[K, V] {
+	return func(l *LRU[K, V]) {
+		l.onAdd = onAdd
+	}
+}
+
+// WithOnUpdate sets a callback invoked when the value of a present key is replaced,
+// by Add, Replace, Update or UpdateNoBump.
+func WithOnUpdate[K comparable, V any](onUpdate func(key K, oldValue, newValue V)) Option[K, V] {
+	return func(l *LRU[K, V]) {
+		l.onUpdate = onUpdate
+	}
+}
+
 // LRU implements a non-thread safe fixed size LRU cache
 type LRU[K comparable, V any] struct {
 	size      int
 	evictList *internal.LRUList[K, V]
 	entries   map[K]*internal.Entry[K, V]
 	onEvict   EvictCallback[K, V]
+	onAdd     func(key K, value V)
+	onUpdate  func(key K, oldValue, newValue V)
+	// removed entries kept for reuse to spare allocations, at most size of them, or maxWeightedFree if weighted
+	free []*internal.Entry[K, V]
+	// source of randomness to pick the evicted entry, nil evicts the oldest one
+	rng *rand.Rand
+	// cost of a value, nil limits the number of entries instead of their total cost
+	cost      func(value V) int64
+	maxCost   int64
+	totalCost int64
 }
 
 // NewLRU constructs an LRU of the given size
-func NewLRU[K comparable, V any](size int, onEvict EvictCallback[K, V]) (*LRU[K, V], error) {
+func NewLRU[K comparable, V any](size int, onEvict EvictCallback[K, V], opts ...Option[K, V]) (*LRU[K, V], error) {
+	return New(size, append([]Option[K, V]{WithOnEvict(onEvict)}, opts...)...)
+}
+
+// New constructs an LRU of the given size configured by the options, e.g. WithOnEvict.
+// Entries of this LRU don't expire, expirable_lru.New takes the TTL options.
+func New[K comparable, V any](size int, opts ...Option[K, V]) (*LRU[K, V], error) {
 	if size <= 0 {
 		return nil, fmt.Errorf("invalid cache size (%d), must be bigger than zero", size)
 	}
@@ -26,9 +84,51 @@ func NewLRU[K comparable, V any](size int, onEvict EvictCallback[K, V]) (*LRU[K,
 		size:      size,
 		evictList: internal.NewList[K, V](),
 		entries:   make(map[K]*internal.Entry[K, V]),
-		onEvict:   onEvict,
 	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l, nil
+}
 
+// NewRandomizedLRU constructs an LRU of the given size which evicts a random entry instead of the oldest one.
+// The chance of an entry to be evicted is proportional to its distance from the newest entry, so the oldest
+// entry is the most likely and the newest entry the least likely to be evicted.
+//
+// Eviction is not deterministic, unless rng is seeded the same way, and walks the list, so it takes O(n) time.
+// It is meant for experiments, e.g. testing the fairness of the callers to eviction order.
+func NewRandomizedLRU[K comparable, V any](size int, rng *rand.Rand) (*LRU[K, V], error) {
+	if rng == nil {
+		return nil, fmt.Errorf("random number generator must not be nil")
+	}
+	l, err := NewLRU[K, V](size, nil)
+	if err != nil {
+		return nil, err
+	}
+	l.rng = rng
+	return l, nil
+}
+
+// NewWeightedLRU constructs an LRU which evicts the oldest entries when the total cost of the values
+// exceeds maxCost instead of limiting the number of entries. The cost of a value is computed when it is
+// added or replaced, so values changed through GetRef keep the cost they had then until they are replaced
+// or removed. A value costing more than
+// maxCost on its own is evicted right after it is added, or replaces the value of a present key,
+// without evicting other entries.
+func NewWeightedLRU[K comparable, V any](maxCost int64, cost func(value V) int64, onEvict EvictCallback[K, V]) (*LRU[K, V], error) {
+	if maxCost <= 0 {
+		return nil, fmt.Errorf("invalid cache cost (%d), must be bigger than zero", maxCost)
+	}
+	if cost == nil {
+		return nil, fmt.Errorf("cost function must not be nil")
+	}
+	l, err := NewLRU[K, V](math.MaxInt, onEvict)
+	if err != nil {
+		return nil, err
+	}
+	l.cost = cost
+	l.maxCost = maxCost
 	return l, nil
 }
 
@@ -38,19 +138,33 @@ func (l *LRU[K, V]) Add(key K, value V) (evicted bool) {
 	// check for existing entry
 	if entry, ok := l.entries[key]; ok {
 		l.evictList.MoveToFront(entry)
-		entry.Value = value
-		return false
+		entry.LastAccess = time.Now()
+		l.setValue(entry, value)
+		return l.evictOverCost(entry)
 	}
 
 	// add new entry
-	entry := l.evictList.PushToFront(key, value)
+	entry := l.pushToFront(key, value)
 	l.entries[key] = entry
+	if l.onAdd != nil {
+		l.onAdd(key, value)
+	}
 
 	evict := l.evictList.Len() > l.size
 	if evict {
 		l.removeOldest()
 	}
-	return evict
+	return l.evictOverCost(entry) || evict
+}
+
+// AddMulti adds the entries to the cache in map iteration order, returns the number of evictions.
+func (l *LRU[K, V]) AddMulti(entries map[K]V) (evicted int) {
+	for key, value := range entries {
+		if l.Add(key, value) {
+			evicted++
+		}
+	}
+	return evicted
 }
 
 // Get returns key's value from the cache and updates the recency of usage of the key.
@@ -58,11 +172,132 @@ func (l *LRU[K, V]) Add(key K, value V) (evicted bool) {
 func (l *LRU[K, V]) Get(key K) (value V, ok bool) {
 	if entry, ok := l.entries[key]; ok {
 		l.evictList.MoveToFront(entry)
+		entry.Accessed(time.Now())
 		return entry.Value, true
 	}
 	return value, false
 }
 
+// GetWithMeta returns key's value from the cache along with its access metadata and updates
+// the recency of usage of the key. The metadata already accounts for this read.
+// ok specifies if the key was found or not.
+func (l *LRU[K, V]) GetWithMeta(key K) (value V, accessCount uint64, lastAccess time.Time, ok bool) {
+	if entry, ok := l.entries[key]; ok {
+		l.evictList.MoveToFront(entry)
+		entry.Accessed(time.Now())
+		return entry.Value, entry.AccessCount, entry.LastAccess, true
+	}
+	return value, 0, lastAccess, false
+}
+
+// GetRef returns a pointer to key's value stored in the cache and updates the recency of usage of the key.
+// It spares copying large values, but writes through the pointer change the cached value without
+// synchronization. Once the key is removed or evicted, the pointer no longer refers to a cached value,
+// the entry is not reused for other keys, so it never reads another key's value.
+// ok specifies if the key was found or not.
+func (l *LRU[K, V]) GetRef(key K) (value *V, ok bool) {
+	if entry, ok := l.entries[key]; ok {
+		l.evictList.MoveToFront(entry)
+		entry.Accessed(time.Now())
+		entry.Referenced = true
+		return &entry.Value, true
+	}
+	return nil, false
+}
+
+// GetAndDemote returns key's value from the cache and makes the key the oldest one,
+// so it is evicted next. It suits entries which are read only once.
+// ok specifies if the key was found or not.
+func (l *LRU[K, V]) GetAndDemote(key K) (value V, ok bool) {
+	if entry, ok := l.entries[key]; ok {
+		l.evictList.MoveToBack(entry)
+		entry.Accessed(time.Now())
+		return entry.Value, true
+	}
+	return value, false
+}
+
+// GetOrZero returns key's value from the cache and updates the recency of usage of the key.
+// If the key is missing, the zero value is added and returned, so that following Gets hit.
+// As reads of missing keys add entries, they can evict other entries.
+// existed specifies if the key was found or not.
+func (l *LRU[K, V]) GetOrZero(key K) (value V, existed bool) {
+	if value, existed = l.Get(key); existed {
+		return value, true
+	}
+	l.Add(key, value)
+	return value, false
+}
+
+// GetOrAdd returns key's value from the cache and updates the recency of usage of the key.
+// If the key is missing, value is added and returned instead. loaded specifies if the key
+// was found or not, evicted if adding the value caused an eviction.
+func (l *LRU[K, V]) GetOrAdd(key K, value V) (actual V, loaded, evicted bool) {
+	if actual, loaded = l.Get(key); loaded {
+		return actual, true, false
+	}
+	evicted = l.Add(key, value)
+	return value, false, evicted
+}
+
+// GetMulti returns the values of the present keys and updates their recency of usage
+// in the given order, so the last found one becomes the newest. The keys not found are returned in missing.
+func (l *LRU[K, V]) GetMulti(keys []K) (values map[K]V, missing []K) {
+	values = make(map[K]V, len(keys))
+	for _, key := range keys {
+		if value, ok := l.Get(key); ok {
+			values[key] = value
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	return values, missing
+}
+
+// Replace replaces key's value and updates the recency of usage of the key.
+// ok specifies if the key was found or not, a missing key is not added.
+func (l *LRU[K, V]) Replace(key K, value V) (ok bool) {
+	if entry, ok := l.entries[key]; ok {
+		l.evictList.MoveToFront(entry)
+		entry.LastAccess = time.Now()
+		l.setValue(entry, value)
+		l.evictOverCost(entry)
+		return true
+	}
+	return false
+}
+
+// Update replaces key's value and updates the recency of usage of the key, like Replace.
+// ok specifies if the key was found or not, a missing key is not added.
+func (l *LRU[K, V]) Update(key K, value V) (ok bool) {
+	return l.Replace(key, value)
+}
+
+// UpdateNoBump replaces key's value without updating the recency of usage of the key.
+// ok specifies if the key was found or not, a missing key is not added.
+func (l *LRU[K, V]) UpdateNoBump(key K, value V) (ok bool) {
+	if entry, ok := l.entries[key]; ok {
+		l.setValue(entry, value)
+		l.evictOverCost(entry)
+		return true
+	}
+	return false
+}
+
+// TouchMany updates the recency of usage of the present keys in the given order,
+// so the last one becomes the newest, returns the number of keys found.
+func (l *LRU[K, V]) TouchMany(keys []K) (touched int) {
+	now := time.Now()
+	for _, key := range keys {
+		if entry, ok := l.entries[key]; ok {
+			l.evictList.MoveToFront(entry)
+			entry.LastAccess = now
+			touched++
+		}
+	}
+	return touched
+}
+
 // Contains checks if a key exists in the cache without updating the recency of usage.
 func (l *LRU[K, V]) Contains(key K) (ok bool) {
 	_, ok = l.entries[key]
@@ -88,11 +323,28 @@ func (l *LRU[K, V]) Remove(key K) (ok bool) {
 	return false
 }
 
+// RemoveFunc removes the entries for which predicate returns true, from oldest to newest,
+// invoking the eviction callback for each of them, returns the number of removed entries.
+// predicate must not modify the cache.
+func (l *LRU[K, V]) RemoveFunc(predicate func(key K, value V) bool) (removed int) {
+	for entry := l.evictList.Back(); entry != nil; {
+		// removed entries are reset for reuse, so step to the next one first
+		prev := entry.PrevEntry()
+		if predicate(entry.Key, entry.Value) {
+			l.removeEntry(entry)
+			removed++
+		}
+		entry = prev
+	}
+	return removed
+}
+
 // RemoveOldest removes the oldest entry from the cache.
 func (l *LRU[K, V]) RemoveOldest() (key K, value V, ok bool) {
 	if entry := l.evictList.Back(); entry != nil {
+		key, value = entry.Key, entry.Value
 		l.removeEntry(entry)
-		return entry.Key, entry.Value, true
+		return key, value, true
 	}
 	return key, value, false
 }
@@ -105,6 +357,30 @@ func (l *LRU[K, V]) GetOldest() (key K, value V, ok bool) {
 	return key, value, false
 }
 
+// GetOldestWithMeta returns the oldest entry from the cache along with the time it was last read or added.
+func (l *LRU[K, V]) GetOldestWithMeta() (key K, value V, lastAccess time.Time, ok bool) {
+	if entry := l.evictList.Back(); entry != nil {
+		return entry.Key, entry.Value, entry.LastAccess, true
+	}
+	return key, value, lastAccess, false
+}
+
+// EvictionRank returns the position of the key from the oldest entry, 0 meaning it is evicted next,
+// without updating the recency of usage. It walks the list, so it takes O(n) time.
+// ok specifies if the key was found or not.
+func (l *LRU[K, V]) EvictionRank(key K) (rank int, ok bool) {
+	if _, ok := l.entries[key]; !ok {
+		return 0, false
+	}
+	for entry := l.evictList.Back(); entry != nil; entry = entry.PrevEntry() {
+		if entry.Key == key {
+			break
+		}
+		rank++
+	}
+	return rank, true
+}
+
 // Keys returns a slice of the keys in the cache, from oldest to newest.
 func (l *LRU[K, V]) Keys() []K {
 	keys := make([]K, l.evictList.Len())
@@ -127,6 +403,104 @@ func (l *LRU[K, V]) Values() []V {
 	return values
 }
 
+// AppendKeys appends the keys in the cache to dst, from oldest to newest, and returns the extended slice.
+func (l *LRU[K, V]) AppendKeys(dst []K) []K {
+	for entry := l.evictList.Back(); entry != nil; entry = entry.PrevEntry() {
+		dst = append(dst, entry.Key)
+	}
+	return dst
+}
+
+// AppendValues appends the values in the cache to dst, from oldest to newest, and returns the extended slice.
+func (l *LRU[K, V]) AppendValues(dst []V) []V {
+	for entry := l.evictList.Back(); entry != nil; entry = entry.PrevEntry() {
+		dst = append(dst, entry.Value)
+	}
+	return dst
+}
+
+// Range calls fn for the entries of the cache from oldest to newest without updating
+// the recency of usage, until fn returns false. fn must not modify the cache.
+func (l *LRU[K, V]) Range(fn func(key K, value V) bool) {
+	for entry := l.evictList.Back(); entry != nil; entry = entry.PrevEntry() {
+		if !fn(entry.Key, entry.Value) {
+			return
+		}
+	}
+}
+
+// RangeReverse calls fn for the entries of the cache from newest to oldest without updating
+// the recency of usage, until fn returns false. fn must not modify the cache.
+func (l *LRU[K, V]) RangeReverse(fn func(key K, value V) bool) {
+	for entry := l.evictList.Front(); entry != nil; entry = entry.NextEntry() {
+		if !fn(entry.Key, entry.Value) {
+			return
+		}
+	}
+}
+
+// All returns an iterator over the entries of the cache from oldest to newest,
+// which doesn't update the recency of usage. The cache must not be modified during the iteration.
+func (l *LRU[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		l.Range(yield)
+	}
+}
+
+// Reduce folds fn over the entries of the cache from oldest to newest, starting with initial.
+func Reduce[K comparable, V any, R any](l *LRU[K, V], initial R, fn func(acc R, key K, value V) R) R {
+	acc := initial
+	for entry := l.evictList.Back(); entry != nil; entry = entry.PrevEntry() {
+		acc = fn(acc, entry.Key, entry.Value)
+	}
+	return acc
+}
+
+// Export returns the cache entries with their metadata, from oldest to newest.
+func (l *LRU[K, V]) Export() []ExportedEntry[K, V] {
+	entries := make([]ExportedEntry[K, V], 0, l.evictList.Len())
+	for entry := l.evictList.Back(); entry != nil; entry = entry.PrevEntry() {
+		entries = append(entries, ExportedEntry[K, V]{
+			Key:         entry.Key,
+			Value:       entry.Value,
+			CreatedAt:   entry.CreatedAt,
+			AccessCount: entry.AccessCount,
+		})
+	}
+	return entries
+}
+
+// Import adds the entries from oldest to newest keeping their metadata,
+// so the entries returned by Export are restored in the same recency order.
+// Returns the number of evicted entries.
+func (l *LRU[K, V]) Import(entries []ExportedEntry[K, V]) (evicted int) {
+	for _, e := range entries {
+		if l.Add(e.Key, e.Value) {
+			evicted++
+		}
+		// the added entry itself is evicted if it's the random victim or costs more than maxCost
+		if entry, ok := l.entries[e.Key]; ok {
+			entry.CreatedAt = e.CreatedAt
+			entry.AccessCount = e.AccessCount
+		}
+	}
+	return evicted
+}
+
+// LowestScoring returns the entry with the lowest score, computed by the score function from the entry
+// and its metadata. It scans all the entries, so it takes O(n) time.
+// ok is false if the cache is empty.
+func (l *LRU[K, V]) LowestScoring(score func(key K, value V, lastAccess time.Time, accessCount uint64) float64) (key K, value V, ok bool) {
+	var lowest float64
+	for entry := l.evictList.Back(); entry != nil; entry = entry.PrevEntry() {
+		s := score(entry.Key, entry.Value, entry.LastAccess, entry.AccessCount)
+		if !ok || s < lowest {
+			key, value, lowest, ok = entry.Key, entry.Value, s, true
+		}
+	}
+	return key, value, ok
+}
+
 // Len returns the number of entries in the cache.
 func (l *LRU[K, V]) Len() int {
 	return l.evictList.Len()
@@ -137,15 +511,71 @@ func (l *LRU[K, V]) Cap() int {
 	return l.size
 }
 
-// Purge clears all the cache entries.
+// Cost returns the total cost of the values in the cache, 0 unless it was made by NewWeightedLRU.
+func (l *LRU[K, V]) Cost() int64 {
+	return l.totalCost
+}
+
+// Purge clears all the cache entries, invoking the eviction callback from oldest to newest.
 func (l *LRU[K, V]) Purge() {
-	for k, v := range l.entries {
+	for entry := l.evictList.Back(); entry != nil; entry = entry.PrevEntry() {
 		if l.onEvict != nil {
-			l.onEvict(k, v.Value)
+			l.onEvict(entry.Key, entry.Value)
 		}
+		delete(l.entries, entry.Key)
+	}
+	l.evictList.Init()
+	l.totalCost = 0
+}
+
+// Drain removes all the cache entries and returns them without invoking the eviction callback.
+func (l *LRU[K, V]) Drain() map[K]V {
+	entries := make(map[K]V, len(l.entries))
+	for k, v := range l.entries {
+		entries[k] = v.Value
 		delete(l.entries, k)
 	}
 	l.evictList.Init()
+	l.totalCost = 0
+	return entries
+}
+
+// Rotate moves all the cache entries to a new cache of the same size without invoking the eviction callback,
+// leaving the cache empty. The returned cache keeps the recency order and has no eviction callback.
+func (l *LRU[K, V]) Rotate() *LRU[K, V] {
+	rotated := &LRU[K, V]{
+		size:      l.size,
+		evictList: l.evictList,
+		entries:   l.entries,
+		cost:      l.cost,
+		maxCost:   l.maxCost,
+		totalCost: l.totalCost,
+	}
+	l.evictList = internal.NewList[K, V]()
+	l.entries = make(map[K]*internal.Entry[K, V])
+	l.totalCost = 0
+	return rotated
+}
+
+// EvictToBytes removes the oldest entries until the total cost of the values is at most targetBytes,
+// returns the number of evicted entries. It evicts nothing unless the cache was made by NewWeightedLRU.
+func (l *LRU[K, V]) EvictToBytes(targetBytes int64) (evicted int) {
+	if l.cost == nil {
+		return 0
+	}
+	for l.totalCost > targetBytes && l.evictList.Len() > 0 {
+		l.removeOldest()
+		evicted++
+	}
+	return evicted
+}
+
+// SwapContents exchanges the entries and the size of two caches, each keeping its callbacks.
+func (l *LRU[K, V]) SwapContents(other *LRU[K, V]) {
+	l.onEvict, other.onEvict = other.onEvict, l.onEvict
+	l.onAdd, other.onAdd = other.onAdd, l.onAdd
+	l.onUpdate, other.onUpdate = other.onUpdate, l.onUpdate
+	*l, *other = *other, *l
 }
 
 // Resize changes the cache size, returning number of evicted entries.
@@ -157,22 +587,118 @@ func (l *LRU[K, V]) Resize(size int) (evicted int) {
 	for i := 0; i < diff; i++ {
 		l.removeOldest()
 	}
+	if keep := max(size, 0); len(l.free) > keep {
+		clear(l.free[keep:])
+		l.free = l.free[:keep]
+	}
 	l.size = size
 	return diff
 }
 
-// removeOldest removes the oldest entry from the cache.
+// removeOldest removes the oldest entry from the cache, or a random one if the cache is randomized.
 func (l *LRU[K, V]) removeOldest() {
+	if l.rng != nil {
+		l.removeRandom()
+		return
+	}
 	if entry := l.evictList.Back(); entry != nil {
 		l.removeEntry(entry)
 	}
 }
 
+// removeRandom removes a random entry, weighing the entries from n for the oldest down to 1 for the newest.
+func (l *LRU[K, V]) removeRandom() {
+	n := int64(l.evictList.Len())
+	if n == 0 {
+		return
+	}
+	r := l.rng.Int63n(n * (n + 1) / 2)
+	weight := n
+	for entry := l.evictList.Back(); entry != nil; entry = entry.PrevEntry() {
+		if r < weight {
+			l.removeEntry(entry)
+			return
+		}
+		r -= weight
+		weight--
+	}
+}
+
 // removeEntry is used to remove a given list entry from the cache
 func (l *LRU[K, V]) removeEntry(entry *internal.Entry[K, V]) {
 	l.evictList.Remove(entry)
 	delete(l.entries, entry.Key)
+	l.totalCost -= entry.Cost
 	if l.onEvict != nil {
 		l.onEvict(entry.Key, entry.Value)
 	}
+	l.recycle(entry)
+}
+
+// setValue replaces the value of an entry, keeping the total cost up to date, and invokes onUpdate.
+func (l *LRU[K, V]) setValue(entry *internal.Entry[K, V], value V) {
+	if l.cost != nil {
+		cost := l.cost(value)
+		l.totalCost += cost - entry.Cost
+		entry.Cost = cost
+	}
+	oldValue := entry.Value
+	entry.Value = value
+	if l.onUpdate != nil {
+		l.onUpdate(entry.Key, oldValue, value)
+	}
+}
+
+// evictOverCost removes the oldest entries while the total cost exceeds the maximum,
+// returns true if an eviction occurred. The just written entry is removed alone
+// if its value costs more than the maximum, so it doesn't empty the cache.
+func (l *LRU[K, V]) evictOverCost(written *internal.Entry[K, V]) (evicted bool) {
+	if l.cost == nil {
+		return false
+	}
+	if written.Cost > l.maxCost {
+		l.removeEntry(written)
+		return true
+	}
+	for l.totalCost > l.maxCost && l.evictList.Len() > 0 {
+		l.removeOldest()
+		evicted = true
+	}
+	return evicted
+}
+
+// pushToFront adds a new entry at the front of the eviction list, reusing a removed entry if there is one.
+func (l *LRU[K, V]) pushToFront(key K, value V) *internal.Entry[K, V] {
+	var entry *internal.Entry[K, V]
+	if n := len(l.free); n == 0 {
+		entry = l.evictList.PushToFront(key, value)
+	} else {
+		entry = l.free[n-1]
+		l.free[n-1] = nil
+		l.free = l.free[:n-1]
+		entry.Key = key
+		entry.Value = value
+		l.evictList.PushEntryToFront(entry)
+	}
+	entry.CreatedAt = time.Now()
+	entry.LastAccess = entry.CreatedAt
+	if l.cost != nil {
+		entry.Cost = l.cost(value)
+		l.totalCost += entry.Cost
+	}
+	return entry
+}
+
+// recycle resets a removed entry, so it doesn't keep the key and value alive, and keeps it for reuse.
+// Entries whose value was handed out by GetRef are left to the garbage collector.
+func (l *LRU[K, V]) recycle(entry *internal.Entry[K, V]) {
+	limit := l.size
+	if l.cost != nil {
+		limit = maxWeightedFree
+	}
+	if entry.Referenced || len(l.free) >= limit {
+		return
+	}
+	*entry = internal.Entry[K, V]{}
+	l.free = append(l.free, entry)
 }
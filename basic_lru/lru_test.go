@@ -0,0 +1,870 @@
+package basic_lru
+
+import (
+	"lru/internal"
+	"maps"
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPurgeOrder(t *testing.T) {
+	var evicted []int
+	l, err := NewLRU[int, int](5, func(key, value int) {
+		evicted = append(evicted, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []int{3, 1, 4, 5, 2} {
+		l.Add(key, key)
+	}
+	l.Get(1)
+	keys := l.Keys()
+
+	l.Purge()
+	if !slices.Equal(evicted, keys) {
+		t.Fatalf("evicted %v, want the order of Keys %v", evicted, keys)
+	}
+	if l.Len() != 0 {
+		t.Fatalf("Len after Purge = %d, want 0", l.Len())
+	}
+}
+
+func TestReduce(t *testing.T) {
+	l, err := NewLRU[int, int](3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := Reduce(l, 10, func(acc, key, value int) int { return acc + value }); sum != 10 {
+		t.Fatalf("Reduce of an empty cache = %d, want the initial value 10", sum)
+	}
+	for i := 1; i <= 4; i++ {
+		l.Add(i, i*10)
+	}
+	if sum := Reduce(l, 0, func(acc, key, value int) int { return acc + value }); sum != 90 {
+		t.Fatalf("Reduce = %d, want 90 as key 1 was evicted", sum)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	l, err := NewLRU[int, string](3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add(1, "one")
+	l.Add(2, "two")
+	l.Add(3, "three")
+
+	if !l.Replace(1, "uno") {
+		t.Fatal("Replace of a present key = false, want true")
+	}
+	if value, _ := l.Peek(1); value != "uno" {
+		t.Fatalf("value after Replace = %q, want %q", value, "uno")
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []int{2, 3, 1}) {
+		t.Fatalf("keys after Replace = %v, want [2 3 1] as the key is promoted", keys)
+	}
+
+	if l.Replace(4, "four") {
+		t.Fatal("Replace of an absent key = true, want false")
+	}
+	if _, ok := l.Peek(4); ok || l.Len() != 3 {
+		t.Fatalf("Replace of an absent key inserted it, len = %d", l.Len())
+	}
+}
+
+func TestRecycledEntries(t *testing.T) {
+	l, err := NewLRU[string, *int](2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	one, two := 1, 2
+	l.Add("a", &one)
+	l.Add("b", &two)
+	l.Get("a")
+	l.Get("a")
+	l.Remove("a")
+	l.Add("c", nil)
+	l.Add("d", nil) // evicts b
+
+	if len(l.free) != 1 {
+		t.Fatalf("free list holds %d entries, want 1", len(l.free))
+	}
+	if entry := l.free[0]; *entry != (internal.Entry[string, *int]{}) {
+		t.Fatalf("recycled entry was not reset: %+v", *entry)
+	}
+	for _, e := range l.Export() {
+		if e.Value != nil || e.AccessCount != 0 {
+			t.Fatalf("entry %q reused a removed entry's state: value %v, access count %d", e.Key, e.Value, e.AccessCount)
+		}
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []string{"c", "d"}) {
+		t.Fatalf("keys = %v, want [c d]", keys)
+	}
+}
+
+func TestChurnDoesNotAllocateEntries(t *testing.T) {
+	l, err := NewLRU[int, int](128, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := 0
+	churn := func() {
+		l.Add(key, key)
+		key++
+	}
+	for range 256 {
+		churn()
+	}
+	if allocs := testing.AllocsPerRun(1000, churn); allocs != 0 {
+		t.Fatalf("Add of a full cache allocates %v times, want 0", allocs)
+	}
+}
+
+func BenchmarkAddChurn(b *testing.B) {
+	l, err := NewLRU[int, int](1024, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Add(i, i)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	src, err := NewLRU[string, int](4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.Add("a", 1)
+	src.Add("b", 2)
+	src.Add("c", 3)
+	src.Get("a")
+	src.Get("a")
+	src.Get("b")
+	exported := src.Export()
+
+	dst, err := NewLRU[string, int](4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evicted := dst.Import(exported); evicted != 0 {
+		t.Fatalf("Import evicted %d entries, want 0", evicted)
+	}
+	if !slices.Equal(dst.Export(), exported) {
+		t.Fatalf("round trip changed the entries:\n got %+v\nwant %+v", dst.Export(), exported)
+	}
+	if keys := dst.Keys(); !slices.Equal(keys, []string{"c", "a", "b"}) {
+		t.Fatalf("keys = %v, want the recency order [c a b]", keys)
+	}
+}
+
+func TestImportOverCost(t *testing.T) {
+	l, err := NewWeightedLRU[string, int64](10, func(value int64) int64 { return value }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add("a", 5)
+	// the oversized entry is evicted by its own import, the others are kept
+	evicted := l.Import([]ExportedEntry[string, int64]{
+		{Key: "big", Value: 20, AccessCount: 3},
+		{Key: "b", Value: 4, AccessCount: 1},
+	})
+	if evicted != 1 {
+		t.Fatalf("Import evicted %d entries, want 1", evicted)
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []string{"a", "b"}) {
+		t.Fatalf("keys = %v, want [a b]", keys)
+	}
+}
+
+func TestRandomizedEvictionSkewsOld(t *testing.T) {
+	const size, trials = 4, 30000
+	rng := rand.New(rand.NewSource(1))
+	counts := make([]int, size+1)
+	for range trials {
+		l, err := NewRandomizedLRU[int, int](size, rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// keys are their position from the oldest, 4 being the newest
+		for key := 0; key <= size; key++ {
+			l.Add(key, key)
+		}
+		for key := 0; key <= size; key++ {
+			if !l.Contains(key) {
+				counts[key]++
+			}
+		}
+	}
+
+	// weights from 5 for the oldest down to 1 for the newest, summing up to 15
+	for key, n := range counts {
+		want := float64(trials) * float64(size+1-key) / 15
+		if diff := float64(n) - want; diff > want*0.1 || diff < -want*0.1 {
+			t.Errorf("key %d evicted %d times, want about %.0f", key, n, want)
+		}
+		if key > 0 && n >= counts[key-1] {
+			t.Errorf("key %d evicted %d times, not less than the older key %d's %d", key, n, key-1, counts[key-1])
+		}
+	}
+}
+
+func TestNewRandomizedLRUNilRand(t *testing.T) {
+	if _, err := NewRandomizedLRU[int, int](1, nil); err == nil {
+		t.Fatal("NewRandomizedLRU with a nil rng returned no error")
+	}
+}
+
+func TestGetAndDemote(t *testing.T) {
+	var evicted []int
+	l, err := NewLRU[int, int](3, func(key, value int) {
+		evicted = append(evicted, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+
+	if value, ok := l.GetAndDemote(3); !ok || value != 3 {
+		t.Fatalf("GetAndDemote(3) = %d, %v, want 3, true", value, ok)
+	}
+	if _, ok := l.GetAndDemote(4); ok {
+		t.Fatal("GetAndDemote of a missing key = true")
+	}
+	// the demoted key goes before the untouched older ones
+	l.Add(4, 4)
+	l.Add(5, 5)
+	if !slices.Equal(evicted, []int{3, 1}) {
+		t.Fatalf("evicted %v, want [3 1]", evicted)
+	}
+}
+
+func TestGetRef(t *testing.T) {
+	l, err := NewLRU[string, [4]int](2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add("a", [4]int{1, 2, 3, 4})
+	l.Add("b", [4]int{})
+
+	ref, ok := l.GetRef("a")
+	if !ok || *ref != [4]int{1, 2, 3, 4} {
+		t.Fatalf("GetRef = %v, %v, want the stored value", ref, ok)
+	}
+	ref[0] = 10
+	if value, _ := l.Peek("a"); value[0] != 10 {
+		t.Fatalf("write through the pointer is not visible, value %v", value)
+	}
+	l.Add("a", [4]int{5})
+	if ref[0] != 5 {
+		t.Fatalf("pointer does not reflect the replaced value, got %v", *ref)
+	}
+	// GetRef updates the recency of usage
+	l.GetRef("b")
+	l.Add("c", [4]int{})
+	if l.Contains("a") || !l.Contains("b") {
+		t.Fatalf("keys = %v, want a evicted", l.Keys())
+	}
+	if ref, ok := l.GetRef("a"); ok || ref != nil {
+		t.Fatalf("GetRef of a missing key = %v, %v, want nil, false", ref, ok)
+	}
+}
+
+func TestGetRefNotRecycled(t *testing.T) {
+	l, err := NewLRU[string, int](2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add("a", 1)
+	ref, _ := l.GetRef("a")
+	l.Remove("a")
+	l.Add("b", 2)
+	// the entry of a isn't reused for b
+	if *ref != 1 || len(l.free) != 0 {
+		t.Fatalf("stale pointer reads %d with %d free entries, want 1 and none", *ref, len(l.free))
+	}
+	*ref = 3
+	if value, _ := l.Peek("b"); value != 2 {
+		t.Fatalf("b = %d after writing through the stale pointer, want 2", value)
+	}
+}
+
+// large is a value type which is expensive to copy.
+type large [512]int64
+
+var sink int64
+
+func BenchmarkGetLarge(b *testing.B) {
+	l, err := NewLRU[int, large](16, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := range 16 {
+		l.Add(i, large{int64(i)})
+	}
+	b.Run("Get", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			value, _ := l.Get(i % 16)
+			sink += value[0]
+		}
+	})
+	b.Run("GetRef", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			value, _ := l.GetRef(i % 16)
+			sink += value[0]
+		}
+	})
+}
+
+func TestLowestScoring(t *testing.T) {
+	l, err := NewLRU[string, int](4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := l.LowestScoring(nil); ok {
+		t.Fatal("LowestScoring of an empty cache = true")
+	}
+	// the value is the size, cheap and often read entries score high
+	l.Add("a", 10)
+	l.Add("b", 50)
+	l.Add("c", 5)
+	for range 3 {
+		l.Get("a")
+	}
+	l.Get("b")
+	score := func(key string, size int, lastAccess time.Time, accessCount uint64) float64 {
+		return float64(accessCount) * 100 / float64(size)
+	}
+	if key, value, ok := l.LowestScoring(score); !ok || key != "c" || value != 5 {
+		t.Fatalf("LowestScoring = %s, %d, %v, want c, 5, true", key, value, ok)
+	}
+	l.Get("c")
+	l.Get("c")
+	if key, _, _ := l.LowestScoring(score); key != "b" {
+		t.Fatalf("LowestScoring after reading c = %s, want b", key)
+	}
+}
+
+func TestLowestScoringLastAccess(t *testing.T) {
+	l, err := NewLRU[string, int](4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leastRecent := func(key string, value int, lastAccess time.Time, accessCount uint64) float64 {
+		return float64(lastAccess.UnixNano())
+	}
+	step := func() { time.Sleep(time.Millisecond) }
+	l.Add("a", 1)
+	step()
+	l.Add("b", 2)
+	step()
+	l.Add("c", 3)
+	step()
+
+	// every write path refreshes the last access time
+	for _, tt := range []struct {
+		write func()
+		want  string
+	}{
+		{func() { l.Replace("a", 10) }, "b"},
+		{func() { l.TouchMany([]string{"b"}) }, "c"},
+		{func() { l.Add("c", 30) }, "a"},
+	} {
+		tt.write()
+		step()
+		if key, _, _ := l.LowestScoring(leastRecent); key != tt.want {
+			t.Fatalf("least recently accessed key = %s, want %s", key, tt.want)
+		}
+	}
+}
+
+func TestGetOrAdd(t *testing.T) {
+	l, err := NewLRU[string, int](2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, loaded, evicted := l.GetOrAdd("a", 1); actual != 1 || loaded || evicted {
+		t.Fatalf("GetOrAdd of a missing key = %d, %v, %v, want 1, false, false", actual, loaded, evicted)
+	}
+	if actual, loaded, evicted := l.GetOrAdd("a", 2); actual != 1 || !loaded || evicted {
+		t.Fatalf("GetOrAdd of a present key = %d, %v, %v, want 1, true, false", actual, loaded, evicted)
+	}
+	l.Add("b", 2)
+	l.GetOrAdd("a", 0)
+	if _, _, evicted := l.GetOrAdd("c", 3); !evicted || l.Contains("b") {
+		t.Fatalf("GetOrAdd of a full cache evicted %v, keys %v, want b evicted", evicted, l.Keys())
+	}
+}
+
+func TestGetWithMeta(t *testing.T) {
+	l, err := NewLRU[string, int](2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add("a", 1)
+	l.Add("b", 2)
+	var last time.Time
+	for i := 1; i <= 3; i++ {
+		before := time.Now()
+		value, accessCount, lastAccess, ok := l.GetWithMeta("a")
+		if !ok || value != 1 || accessCount != uint64(i) {
+			t.Fatalf("read %d: GetWithMeta = %d, %d, %v, want 1, %d, true", i, value, accessCount, ok, i)
+		}
+		if lastAccess.Before(before) || lastAccess.Before(last) {
+			t.Fatalf("read %d: last access %v is older than the read", i, lastAccess)
+		}
+		last = lastAccess
+	}
+	// the reads promoted the key
+	if keys := l.Keys(); !slices.Equal(keys, []string{"b", "a"}) {
+		t.Fatalf("keys = %v, want [b a]", keys)
+	}
+	if _, accessCount, lastAccess, ok := l.GetWithMeta("missing"); ok || accessCount != 0 || !lastAccess.IsZero() {
+		t.Fatalf("GetWithMeta of a missing key = %d, %v, %v, want 0, zero time, false", accessCount, lastAccess, ok)
+	}
+}
+
+func TestRange(t *testing.T) {
+	l, err := NewLRU[int, int](4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 4; i++ {
+		l.Add(i, i*10)
+	}
+	l.Get(2)
+
+	collect := func(rangeFn func(fn func(key, value int) bool), limit int) []int {
+		var keys []int
+		rangeFn(func(key, value int) bool {
+			if value != key*10 {
+				t.Fatalf("key %d has value %d", key, value)
+			}
+			keys = append(keys, key)
+			return len(keys) < limit
+		})
+		return keys
+	}
+	if keys := collect(l.Range, 10); !slices.Equal(keys, []int{1, 3, 4, 2}) {
+		t.Fatalf("Range visited %v, want [1 3 4 2]", keys)
+	}
+	if keys := collect(l.RangeReverse, 10); !slices.Equal(keys, []int{2, 4, 3, 1}) {
+		t.Fatalf("RangeReverse visited %v, want [2 4 3 1]", keys)
+	}
+	if keys := collect(l.Range, 2); !slices.Equal(keys, []int{1, 3}) {
+		t.Fatalf("Range visited %v before stopping, want [1 3]", keys)
+	}
+	if keys := collect(l.RangeReverse, 1); !slices.Equal(keys, []int{2}) {
+		t.Fatalf("RangeReverse visited %v before stopping, want [2]", keys)
+	}
+	// ranging doesn't update the recency of usage
+	if keys := l.Keys(); !slices.Equal(keys, []int{1, 3, 4, 2}) {
+		t.Fatalf("keys after ranging = %v, want [1 3 4 2]", keys)
+	}
+}
+
+func TestAll(t *testing.T) {
+	l, err := NewLRU[string, int](3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add("a", 1)
+	l.Add("b", 2)
+	l.Add("c", 3)
+	l.Get("a")
+
+	if entries := maps.Collect(l.All()); len(entries) != 3 || entries["c"] != 3 {
+		t.Fatalf("collected %v, want 3 entries", entries)
+	}
+	var keys []string
+	for key, value := range l.All() {
+		if value != int(key[0]-'a')+1 {
+			t.Fatalf("key %s has value %d", key, value)
+		}
+		keys = append(keys, key)
+	}
+	if !slices.Equal(keys, l.Keys()) {
+		t.Fatalf("All yielded %v, want the order of Keys %v", keys, l.Keys())
+	}
+	for key := range l.All() {
+		if key != "b" {
+			t.Fatalf("first key = %s, want b", key)
+		}
+		break
+	}
+}
+
+func TestGetMulti(t *testing.T) {
+	l, err := NewLRU[int, string](4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range []string{"zero", "one", "two", "three"} {
+		l.Add(i, s)
+	}
+	values, missing := l.GetMulti([]int{2, 7, 0, 9})
+	if !maps.Equal(values, map[int]string{0: "zero", 2: "two"}) {
+		t.Fatalf("values = %v, want 0 and 2", values)
+	}
+	if !slices.Equal(missing, []int{7, 9}) {
+		t.Fatalf("missing = %v, want [7 9]", missing)
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []int{1, 3, 2, 0}) {
+		t.Fatalf("keys = %v, want [1 3 2 0] with the found keys promoted in order", keys)
+	}
+}
+
+func TestAddMulti(t *testing.T) {
+	l, err := NewLRU[int, int](3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add(0, 0)
+	if evicted := l.AddMulti(map[int]int{1: 1, 2: 2, 3: 3, 4: 4}); evicted != 2 {
+		t.Fatalf("AddMulti evicted %d entries, want 2", evicted)
+	}
+	if l.Len() != 3 || l.Contains(0) {
+		t.Fatalf("keys = %v, want 3 of the added keys", l.Keys())
+	}
+}
+
+func TestAppendKeysValues(t *testing.T) {
+	l, err := NewLRU[int, string](8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range []string{"zero", "one", "two"} {
+		l.Add(i, s)
+	}
+	l.Get(0)
+
+	keys := l.AppendKeys([]int{-1})
+	if !slices.Equal(keys, []int{-1, 1, 2, 0}) {
+		t.Fatalf("AppendKeys = %v, want [-1 1 2 0]", keys)
+	}
+	values := l.AppendValues(nil)
+	if !slices.Equal(values, []string{"one", "two", "zero"}) {
+		t.Fatalf("AppendValues = %v, want [one two zero]", values)
+	}
+
+	keyBuf := make([]int, 0, 8)
+	valueBuf := make([]string, 0, 8)
+	allocs := testing.AllocsPerRun(100, func() {
+		keyBuf = l.AppendKeys(keyBuf[:0])
+		valueBuf = l.AppendValues(valueBuf[:0])
+	})
+	if allocs != 0 {
+		t.Fatalf("appending to pre-sized slices allocates %v times, want 0", allocs)
+	}
+	if !slices.Equal(keyBuf, []int{1, 2, 0}) {
+		t.Fatalf("reused key buffer = %v, want [1 2 0]", keyBuf)
+	}
+}
+
+func TestWeightedLRU(t *testing.T) {
+	var evicted []string
+	l, err := NewWeightedLRU[string, string](10, func(value string) int64 {
+		return int64(len(value))
+	}, func(key, value string) {
+		evicted = append(evicted, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add("a", "xxx")
+	l.Add("b", "xxxx")
+	l.Add("c", "xx")
+	if l.Cost() != 9 || len(evicted) != 0 {
+		t.Fatalf("cost = %d, evicted %v, want 9 and no evictions", l.Cost(), evicted)
+	}
+
+	// eviction stops once the total fits
+	if !l.Add("d", "xxxx") {
+		t.Fatal("Add over the maximum cost reported no eviction")
+	}
+	if !slices.Equal(evicted, []string{"a"}) || l.Cost() != 10 {
+		t.Fatalf("evicted %v with cost %d, want [a] and 10", evicted, l.Cost())
+	}
+
+	// updating a key adjusts the total by the difference
+	l.Add("c", "x")
+	if l.Cost() != 9 {
+		t.Fatalf("cost after shrinking c = %d, want 9", l.Cost())
+	}
+	l.Add("c", "xxx")
+	if !slices.Equal(evicted, []string{"a", "b"}) || l.Cost() != 7 {
+		t.Fatalf("evicted %v with cost %d after growing c, want [a b] and 7", evicted, l.Cost())
+	}
+	l.Remove("d")
+	if l.Cost() != 3 {
+		t.Fatalf("cost after Remove = %d, want 3", l.Cost())
+	}
+}
+
+func TestWeightedLRUOversized(t *testing.T) {
+	var evicted []string
+	l, err := NewWeightedLRU[string, int64](10, func(value int64) int64 { return value }, func(key string, value int64) {
+		evicted = append(evicted, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add("a", 4)
+	l.Add("b", 4)
+
+	// a value costing more than the maximum is evicted alone instead of emptying the cache
+	if !l.Add("big", 11) {
+		t.Fatal("Add of an oversized value reported no eviction")
+	}
+	if !slices.Equal(evicted, []string{"big"}) || !slices.Equal(l.Keys(), []string{"a", "b"}) || l.Cost() != 8 {
+		t.Fatalf("evicted %v, keys %v, cost %d, want [big], [a b], 8", evicted, l.Keys(), l.Cost())
+	}
+	l.Add("a", 11)
+	if !slices.Equal(evicted, []string{"big", "a"}) || !slices.Equal(l.Keys(), []string{"b"}) || l.Cost() != 4 {
+		t.Fatalf("evicted %v, keys %v, cost %d, want [big a], [b], 4", evicted, l.Keys(), l.Cost())
+	}
+
+	if _, err := NewWeightedLRU[string, int64](0, func(value int64) int64 { return value }, nil); err == nil {
+		t.Fatal("NewWeightedLRU with a maximum cost of 0 returned no error")
+	}
+}
+
+func TestWeightedLRUGetRef(t *testing.T) {
+	l, err := NewWeightedLRU[string, []byte](100, func(value []byte) int64 { return int64(len(value)) }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add("a", make([]byte, 10))
+	ref, _ := l.GetRef("a")
+	*ref = make([]byte, 50)
+
+	// the value keeps the cost it was added with until it is written again
+	if l.Cost() != 10 {
+		t.Fatalf("cost after growing through GetRef = %d, want 10", l.Cost())
+	}
+	l.Add("b", make([]byte, 5))
+	l.Replace("b", make([]byte, 7))
+	l.Remove("a")
+	if l.Cost() != 7 {
+		t.Fatalf("cost after removing a = %d, want 7", l.Cost())
+	}
+	l.Remove("b")
+	if l.Cost() != 0 {
+		t.Fatalf("cost of an empty cache = %d, want 0", l.Cost())
+	}
+}
+
+func TestWeightedLRUFreeEntries(t *testing.T) {
+	l, err := NewWeightedLRU[int, int64](1<<20, func(value int64) int64 { return value }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 1000 {
+		l.Add(i, 1)
+	}
+	for i := range 1000 {
+		l.Remove(i)
+	}
+	// the size of a weighted cache doesn't bound the number of entries, so it doesn't bound the free list
+	if len(l.free) != maxWeightedFree {
+		t.Fatalf("free list holds %d entries, want %d", len(l.free), maxWeightedFree)
+	}
+}
+
+func TestEvictToBytes(t *testing.T) {
+	var evicted []string
+	l, err := NewWeightedLRU[string, []byte](100, func(value []byte) int64 {
+		return int64(len(value))
+	}, func(key string, value []byte) {
+		evicted = append(evicted, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []struct {
+		key  string
+		size int
+	}{{"a", 30}, {"b", 20}, {"c", 25}, {"d", 15}} {
+		l.Add(e.key, make([]byte, e.size))
+	}
+	l.Get("a")
+
+	// b and c have to go to get from 90 down to 45
+	if n := l.EvictToBytes(50); n != 2 {
+		t.Fatalf("EvictToBytes evicted %d entries, want 2", n)
+	}
+	if !slices.Equal(evicted, []string{"b", "c"}) || l.Cost() != 45 {
+		t.Fatalf("evicted %v with cost %d left, want [b c] and 45", evicted, l.Cost())
+	}
+	if n := l.EvictToBytes(45); n != 0 {
+		t.Fatalf("EvictToBytes at the target evicted %d entries, want 0", n)
+	}
+	if n := l.EvictToBytes(0); n != 2 || l.Len() != 0 {
+		t.Fatalf("EvictToBytes(0) evicted %d entries, left %d, want all", n, l.Len())
+	}
+
+	plain, err := NewLRU[int, int](2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain.Add(1, 1)
+	if n := plain.EvictToBytes(0); n != 0 {
+		t.Fatalf("EvictToBytes of a cache without costs evicted %d entries, want 0", n)
+	}
+}
+
+func TestRemoveFunc(t *testing.T) {
+	var evicted []int
+	l, err := NewLRU[int, int](10, func(key, value int) {
+		evicted = append(evicted, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		l.Add(i, i)
+	}
+	isEven := func(key, value int) bool { return key%2 == 0 }
+	if removed := l.RemoveFunc(isEven); removed != 5 {
+		t.Fatalf("RemoveFunc = %d, want 5", removed)
+	}
+	if !slices.Equal(evicted, []int{0, 2, 4, 6, 8}) {
+		t.Fatalf("evicted %v, want [0 2 4 6 8]", evicted)
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []int{1, 3, 5, 7, 9}) {
+		t.Fatalf("keys = %v, want [1 3 5 7 9]", keys)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	var evicted []int
+	l, err := NewLRU[int, int](2, func(key, value int) {
+		evicted = append(evicted, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+
+	if !l.Update(1, 10) {
+		t.Fatal("Update of a present key = false")
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []int{2, 1}) {
+		t.Fatalf("keys after Update = %v, want [2 1] as the key is promoted", keys)
+	}
+	if !l.UpdateNoBump(2, 20) {
+		t.Fatal("UpdateNoBump of a present key = false")
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []int{2, 1}) {
+		t.Fatalf("keys after UpdateNoBump = %v, want [2 1] unchanged", keys)
+	}
+	if values := l.Values(); !slices.Equal(values, []int{20, 10}) {
+		t.Fatalf("values = %v, want [20 10]", values)
+	}
+
+	// absent keys are neither added nor make room by evicting
+	if l.Update(3, 3) || l.UpdateNoBump(4, 4) {
+		t.Fatal("Update of an absent key = true")
+	}
+	if l.Len() != 2 || len(evicted) != 0 {
+		t.Fatalf("len %d, evicted %v after updating absent keys, want 2 and none", l.Len(), evicted)
+	}
+}
+
+func TestEvictionRank(t *testing.T) {
+	l, err := NewLRU[string, int](4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range []string{"a", "b", "c", "d"} {
+		l.Add(key, i)
+	}
+	l.Get("a")
+	l.Peek("b")
+
+	// Peek doesn't move b, Get makes a the newest
+	for rank, key := range []string{"b", "c", "d", "a"} {
+		if got, ok := l.EvictionRank(key); !ok || got != rank {
+			t.Fatalf("EvictionRank(%q) = %d, %v, want %d, true", key, got, ok, rank)
+		}
+	}
+	if _, ok := l.EvictionRank("missing"); ok {
+		t.Fatal("EvictionRank of a missing key = true")
+	}
+	// the rank doesn't change the order
+	if keys := l.Keys(); !slices.Equal(keys, []string{"b", "c", "d", "a"}) {
+		t.Fatalf("keys = %v, want [b c d a]", keys)
+	}
+}
+
+func TestOnAddOnUpdate(t *testing.T) {
+	var adds, updates []string
+	l, err := NewLRU[string, int](2, nil,
+		WithOnAdd(func(key string, value int) {
+			adds = append(adds, key)
+		}),
+		WithOnUpdate(func(key string, oldValue, newValue int) {
+			if oldValue+1 != newValue {
+				t.Errorf("update of %s from %d to %d, want the next value", key, oldValue, newValue)
+			}
+			updates = append(updates, key)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add("a", 1)
+	l.Add("b", 1)
+	l.Add("a", 2)
+	l.Add("c", 1) // evicts b
+	l.Add("b", 1)
+
+	if !slices.Equal(adds, []string{"a", "b", "c", "b"}) {
+		t.Fatalf("adds %v, want [a b c b]", adds)
+	}
+	if !slices.Equal(updates, []string{"a"}) {
+		t.Fatalf("updates %v, want [a]", updates)
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	var evicted, added []int
+	l, err := New(1,
+		WithOnEvict(func(key, value int) {
+			evicted = append(evicted, key)
+		}),
+		WithOnAdd(func(key, value int) {
+			added = append(added, key)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	if !slices.Equal(evicted, []int{1}) || !slices.Equal(added, []int{1, 2}) {
+		t.Fatalf("evicted %v and added %v, want [1] and [1 2]", evicted, added)
+	}
+
+	if _, err := New[int, int](0); err == nil {
+		t.Fatal("New with size 0 returned no error")
+	}
+	// NewLRU is New with WithOnEvict
+	evicted = nil
+	l, err = NewLRU(1, func(key, value int) {
+		evicted = append(evicted, key)
+	}, WithOnAdd(func(key, value int) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	if !slices.Equal(evicted, []int{1}) {
+		t.Fatalf("evicted %v, want [1]", evicted)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+// Op is an operation of ApplyBatch, either setting the value of a key or deleting the key.
+// Make it with SetOp or DeleteOp.
+type Op[K comparable, V any] struct {
+	Key   K
+	Value V
+	// Delete removes Key instead of setting it to Value
+	Delete bool
+}
+
+// SetOp returns the operation adding the value of the key.
+func SetOp[K comparable, V any](key K, value V) Op[K, V] {
+	return Op[K, V]{Key: key, Value: value}
+}
+
+// DeleteOp returns the operation removing the key.
+func DeleteOp[K comparable, V any](key K) Op[K, V] {
+	return Op[K, V]{Key: key, Delete: true}
+}
+
+// ApplyBatch applies the operations in order under one lock acquisition, so readers see
+// the cache either before or after the whole batch. Returns the number of entries evicted
+// to make room for the set keys. The eviction callback is invoked for each evicted
+// or deleted entry, in order, after the lock is released.
+func (c *Cache[K, V]) ApplyBatch(ops []Op[K, V]) (evicted int) {
+	var (
+		keys     []K
+		values   []V
+		capacity []bool
+	)
+	c.lock.Lock()
+	for _, op := range ops {
+		if op.Delete {
+			c.lru.Remove(op.Key)
+		} else {
+			c.lru.Add(op.Key, op.Value)
+			c.adds.Add(1)
+		}
+		// whatever the operation removed is at the end of the buffer
+		for len(capacity) < len(c.evictedKeys) {
+			capacity = append(capacity, !op.Delete)
+			if !op.Delete {
+				evicted++
+			}
+		}
+	}
+	c.evictions.Add(uint64(evicted))
+	c.addEvictions.Add(uint64(evicted))
+	if len(c.evictedKeys) > 0 {
+		keys, values = c.evictedKeys, c.evictedValues
+		c.initEvictBuffers()
+	}
+	writes := c.takeWrites()
+	notifyFullness := c.checkFullness()
+	c.lock.Unlock()
+	for _, op := range ops {
+		if !op.Delete {
+			c.observeAdd(op.Key)
+		}
+	}
+	c.notifyWrites(writes)
+	for i := 0; i < len(keys); i++ {
+		if capacity[i] {
+			c.notifyCapacityEvicted(keys[i], values[i])
+		} else {
+			c.notifyEvicted(keys[i], values[i])
+		}
+	}
+	if notifyFullness != nil {
+		notifyFullness()
+	}
+	return evicted
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestApplyBatch(t *testing.T) {
+	var evicted []string
+	c, err := NewWithOnEvict[string, int](3, func(key string, value int) {
+		evicted = append(evicted, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add("a", 1)
+	n := c.ApplyBatch([]Op[string, int]{
+		SetOp("b", 2),
+		DeleteOp[string, int]("a"),
+		SetOp("c", 3),
+		SetOp("d", 4),
+		SetOp("e", 5), // evicts b
+		SetOp("c", 30),
+		DeleteOp[string, int]("missing"),
+	})
+	if n != 1 {
+		t.Fatalf("ApplyBatch() = %d evictions, want 1", n)
+	}
+	if keys := c.Keys(); !slices.Equal(keys, []string{"d", "e", "c"}) {
+		t.Fatalf("keys = %v, want [d e c]", keys)
+	}
+	if value, _ := c.Peek("c"); value != 30 {
+		t.Fatalf("c = %d, want 30", value)
+	}
+	if !slices.Equal(evicted, []string{"a", "b"}) {
+		t.Fatalf("evicted %v, want [a b]", evicted)
+	}
+}
+
+func TestApplyBatchAtomic(t *testing.T) {
+	c, _ := New[string, int](8)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := range 1000 {
+			c.ApplyBatch([]Op[string, int]{SetOp("x", i), SetOp("y", i)})
+			c.ApplyBatch([]Op[string, int]{DeleteOp[string, int]("x"), DeleteOp[string, int]("y")})
+		}
+	}()
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				// either both keys with the same value or neither
+				entries := c.Entries()
+				x, okX := entries["x"]
+				y, okY := entries["y"]
+				if okX != okY || x != y {
+					t.Errorf("partial batch seen: %v", entries)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// defaultFalsePositiveRate is used by KeyBloom for a rate out of (0, 1).
+const defaultFalsePositiveRate = 0.01
+
+// Bloom is a Bloom filter of keys, as returned by KeyBloom. It tells that a key is certainly
+// not in the set, or that it might be, with the false positive rate the filter was built for.
+// Keys are hashed by their fmt %v form, which doesn't depend on the process, so a filter built
+// by one node can be checked by another one with the same key type.
+type Bloom[K comparable] struct {
+	bits []uint64
+	// number of hashes per key
+	hashes uint64
+}
+
+// newBloom returns a filter sized for n keys and the false positive rate.
+func newBloom[K comparable](n int, falsePositiveRate float64) *Bloom[K] {
+	n = max(n, 1)
+	m := math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := max(math.Round(m/float64(n)*math.Ln2), 1)
+	return &Bloom[K]{
+		bits:   make([]uint64, (uint64(m)+63)/64),
+		hashes: uint64(k),
+	}
+}
+
+// KeyBloom returns a Bloom filter of the current keys with the given false positive rate,
+// 0.01 if it's not between 0 and 1. The keys are snapshotted under the lock, later changes
+// of the cache are not reflected in the filter.
+func (c *Cache[K, V]) KeyBloom(falsePositiveRate float64) *Bloom[K] {
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = defaultFalsePositiveRate
+	}
+	keys := c.Keys()
+	b := newBloom[K](len(keys), falsePositiveRate)
+	for _, key := range keys {
+		b.add(key)
+	}
+	return b
+}
+
+// MightContain reports whether the key might be in the filter. False means it certainly isn't.
+func (b *Bloom[K]) MightContain(key K) bool {
+	h1, h2 := b.hash(key)
+	m := uint64(len(b.bits)) * 64
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// add sets the bits of the key.
+func (b *Bloom[K]) add(key K) {
+	h1, h2 := b.hash(key)
+	m := uint64(len(b.bits)) * 64
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// hash returns the two hashes of the key combined into the hashes of the filter.
+func (b *Bloom[K]) hash(key K) (h1, h2 uint64) {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%v", key)
+	sum := h.Sum64()
+	// the second hash is the first one with its halves swapped, odd so it's never 0
+	return sum, sum>>32 | sum<<32 | 1
+}
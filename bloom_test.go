@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestKeyBloom(t *testing.T) {
+	const n, probes = 1000, 20000
+	c, _ := New[int, int](n)
+	for i := range n {
+		c.Add(i, i)
+	}
+	for _, rate := range []float64{0.01, 0.1} {
+		b := c.KeyBloom(rate)
+		for i := range n {
+			if !b.MightContain(i) {
+				t.Fatalf("rate %v: present key %d not in the filter", rate, i)
+			}
+		}
+		falsePositives := 0
+		for i := n; i < n+probes; i++ {
+			if b.MightContain(i) {
+				falsePositives++
+			}
+		}
+		if got := float64(falsePositives) / probes; got > 2*rate {
+			t.Fatalf("false positive rate %v, want about %v", got, rate)
+		}
+	}
+}
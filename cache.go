@@ -1,8 +1,11 @@
 package main
 
 import (
+	"iter"
 	"lru/basic_lru"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -16,7 +19,43 @@ type Cache[K comparable, V any] struct {
 	evictedKeys   []K
 	evictedValues []V
 	onEvict       func(key K, value V)
-	lock          sync.RWMutex
+	observer      Observer[K, V]
+	// called by the writes of new and present keys
+	onAdd    func(key K, value V)
+	onUpdate func(key K, oldValue, newValue V)
+	// writes of the basic LRU waiting for onAdd and onUpdate until the lock is released
+	writes []write[K, V]
+	lock   sync.RWMutex
+	// keys being loaded through reservations
+	loads map[K]*load[V]
+	// whether the cache was full when last checked and the callback invoked when that changes
+	full             bool
+	onFullnessChange func(full bool)
+	// largest number of entries held, updated along with full
+	maxLen int
+	// whether Get leaves the recency of usage untouched
+	recencySuspended bool
+
+	// eviction listeners, replaced as a whole so they can be read without the lock
+	evictListeners atomic.Pointer[[]*evictListener[K, V]]
+	// callback for the entries evicted to make room for added ones, read without the lock
+	onCapacityEvict atomic.Pointer[func(key K, value V)]
+	// contents of the cache as of the last PublishSnapshot, read without the lock
+	published atomic.Pointer[map[K]V]
+	// values of AddDebounced waiting to be added
+	debouncer debouncer[K, V]
+
+	// statistics, atomic to be updated under the read lock
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+	// calls of Add and the evictions they caused
+	adds         atomic.Uint64
+	addEvictions atomic.Uint64
+	// hits and misses of the recent intervals
+	window hitWindow
+	// source of the time, nil for the real time
+	clock clock
 }
 
 // New creates an LRU of the given size.
@@ -27,14 +66,25 @@ func New[K comparable, V any](size int) (*Cache[K, V], error) {
 func NewWithOnEvict[K comparable, V any](size int, onEvict func(key K, value V)) (c *Cache[K, V], err error) {
 	// create a cache with default settings
 	c = &Cache[K, V]{onEvict: onEvict}
-	if onEvict != nil {
-		c.initEvictBuffers()
-		onEvict = c.onEvictCB
-	}
-	c.lru, err = basic_lru.NewLRU(size, onEvict)
+	// evictions are always buffered as listeners can be added later
+	c.initEvictBuffers()
+	c.lru, err = basic_lru.NewLRU(size, c.onEvictCB, c.writeCallbacks()...)
 	return c, err
 }
 
+// NewWithCallbacks creates an LRU of the given size with callbacks invoked by all the writes, e.g. Add,
+// Replace or GetOrAdd, outside the lock, onAdd when a new key is added and onUpdate when the value
+// of a present key is replaced.
+// Any of the callbacks can be nil.
+func NewWithCallbacks[K comparable, V any](size int, onEvict, onAdd func(key K, value V), onUpdate func(key K, oldValue, newValue V)) (*Cache[K, V], error) {
+	c, err := NewWithOnEvict(size, onEvict)
+	if err != nil {
+		return nil, err
+	}
+	c.onAdd, c.onUpdate = onAdd, onUpdate
+	return c, nil
+}
+
 func (c *Cache[K, V]) initEvictBuffers() {
 	c.evictedKeys = make([]K, 0, DefaultEvictedBufferSize)
 	c.evictedValues = make([]V, 0, DefaultEvictedBufferSize)
@@ -45,6 +95,48 @@ func (c *Cache[K, V]) onEvictCB(key K, value V) {
 	c.evictedValues = append(c.evictedValues, value)
 }
 
+// write is a write of the basic LRU for onAdd or onUpdate.
+type write[K comparable, V any] struct {
+	key             K
+	oldValue, value V
+	update          bool
+}
+
+// writeCallbacks returns the options of the basic LRU buffering its writes for onAdd and onUpdate.
+func (c *Cache[K, V]) writeCallbacks() []basic_lru.Option[K, V] {
+	return []basic_lru.Option[K, V]{
+		basic_lru.WithOnAdd(func(key K, value V) {
+			if c.onAdd != nil {
+				c.writes = append(c.writes, write[K, V]{key: key, value: value})
+			}
+		}),
+		basic_lru.WithOnUpdate(func(key K, oldValue, value V) {
+			if c.onUpdate != nil {
+				c.writes = append(c.writes, write[K, V]{key: key, oldValue: oldValue, value: value, update: true})
+			}
+		}),
+	}
+}
+
+// takeWrites returns the buffered writes, to be passed to notifyWrites after the lock is released.
+// Has to be called with lock!
+func (c *Cache[K, V]) takeWrites() []write[K, V] {
+	writes := c.writes
+	c.writes = nil
+	return writes
+}
+
+// notifyWrites invokes onAdd or onUpdate for each of the writes.
+func (c *Cache[K, V]) notifyWrites(writes []write[K, V]) {
+	for _, w := range writes {
+		if w.update {
+			c.onUpdate(w.key, w.oldValue, w.value)
+		} else {
+			c.onAdd(w.key, w.value)
+		}
+	}
+}
+
 // Add adds an entry to the cache, returns true if an eviction occurred and
 // updates the recency of usage of the key.
 func (c *Cache[K, V]) Add(key K, value V) (evicted bool) {
@@ -54,13 +146,58 @@ func (c *Cache[K, V]) Add(key K, value V) (evicted bool) {
 	)
 	c.lock.Lock()
 	evicted = c.lru.Add(key, value)
-	if evicted && c.onEvict != nil {
+	c.adds.Add(1)
+	if evicted {
+		c.evictions.Add(1)
+		c.addEvictions.Add(1)
+	}
+	if evicted {
 		k, v = c.evictedKeys[0], c.evictedValues[0]
 		c.evictedKeys, c.evictedValues = c.evictedKeys[:0], c.evictedValues[:0]
 	}
+	writes := c.takeWrites()
+	notifyFullness := c.checkFullness()
 	c.lock.Unlock()
-	if evicted && c.onEvict != nil {
-		c.onEvict(k, v)
+	c.observeAdd(key)
+	c.notifyWrites(writes)
+	if evicted {
+		c.notifyCapacityEvicted(k, v)
+	}
+	if notifyFullness != nil {
+		notifyFullness()
+	}
+	return evicted
+}
+
+// AddMulti adds the entries to the cache under one lock acquisition in map iteration order,
+// returns the number of evictions. The eviction callback is invoked for each evicted entry
+// after the lock is released.
+func (c *Cache[K, V]) AddMulti(entries map[K]V) (evicted int) {
+	var (
+		keys   []K
+		values []V
+	)
+	c.lock.Lock()
+	evicted = c.lru.AddMulti(entries)
+	c.adds.Add(uint64(len(entries)))
+	c.evictions.Add(uint64(evicted))
+	c.addEvictions.Add(uint64(evicted))
+	if evicted > 0 {
+		keys, values = c.evictedKeys, c.evictedValues
+		c.initEvictBuffers()
+	}
+	writes := c.takeWrites()
+	notifyFullness := c.checkFullness()
+	c.lock.Unlock()
+	for key := range entries {
+		c.observeAdd(key)
+	}
+	c.notifyWrites(writes)
+	for i := 0; i < len(keys); i++ {
+		c.notifyCapacityEvicted(keys[i], values[i])
+	}
+	if notifyFullness != nil {
+		notifyFullness()
 	}
 	return evicted
 }
@@ -69,11 +206,149 @@ func (c *Cache[K, V]) Add(key K, value V) (evicted bool) {
 // ok specifies if the key was found or not.
 func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
 	c.lock.Lock()
-	value, ok = c.lru.Get(key)
+	if c.recencySuspended {
+		value, ok = c.lru.Peek(key)
+	} else {
+		value, ok = c.lru.Get(key)
+	}
+	c.countLookup(ok)
+	c.lock.Unlock()
+	c.observeLookup(key, ok)
+	return value, ok
+}
+
+// GetAndDemote returns key's value from the cache and makes the key the oldest one,
+// so it is evicted next. It suits entries which are read only once.
+// ok specifies if the key was found or not.
+func (c *Cache[K, V]) GetAndDemote(key K) (value V, ok bool) {
+	c.lock.Lock()
+	value, ok = c.lru.GetAndDemote(key)
+	c.countLookup(ok)
 	c.lock.Unlock()
+	c.observeLookup(key, ok)
 	return value, ok
 }
 
+// SuspendRecency makes Get stop updating the recency of usage of the keys, like Peek,
+// until ResumeRecency is called. It keeps the order stable during a bulk read.
+func (c *Cache[K, V]) SuspendRecency() {
+	c.lock.Lock()
+	c.recencySuspended = true
+	c.lock.Unlock()
+}
+
+// ResumeRecency makes Get update the recency of usage of the keys again.
+func (c *Cache[K, V]) ResumeRecency() {
+	c.lock.Lock()
+	c.recencySuspended = false
+	c.lock.Unlock()
+}
+
+// GetOrZero returns key's value from the cache and updates the recency of usage of the key.
+// If the key is missing, the zero value is added and returned, so that following Gets hit.
+// As reads of missing keys add entries, they can evict other entries.
+// existed specifies if the key was found or not.
+func (c *Cache[K, V]) GetOrZero(key K) (value V, existed bool) {
+	var (
+		k       K
+		v       V
+		evicted bool
+	)
+	c.lock.Lock()
+	value, existed = c.lru.Get(key)
+	c.countLookup(existed)
+	if !existed {
+		evicted = c.lru.Add(key, value)
+	}
+	if evicted {
+		c.evictions.Add(1)
+	}
+	if evicted {
+		k, v = c.evictedKeys[0], c.evictedValues[0]
+		c.evictedKeys, c.evictedValues = c.evictedKeys[:0], c.evictedValues[:0]
+	}
+	writes := c.takeWrites()
+	notifyFullness := c.checkFullness()
+	c.lock.Unlock()
+	c.observeLookup(key, existed)
+	if !existed {
+		c.observeAdd(key)
+	}
+	c.notifyWrites(writes)
+	if evicted {
+		c.notifyCapacityEvicted(k, v)
+	}
+	if notifyFullness != nil {
+		notifyFullness()
+	}
+	return value, existed
+}
+
+// Replace replaces key's value and updates the recency of usage of the key.
+// ok specifies if the key was found or not, a missing key is not added.
+func (c *Cache[K, V]) Replace(key K, value V) (ok bool) {
+	c.lock.Lock()
+	ok = c.lru.Replace(key, value)
+	writes := c.takeWrites()
+	c.lock.Unlock()
+	c.notifyWrites(writes)
+	return ok
+}
+
+// Update replaces key's value and updates the recency of usage of the key, like Replace.
+// ok specifies if the key was found or not, a missing key is not added.
+func (c *Cache[K, V]) Update(key K, value V) (ok bool) {
+	return c.Replace(key, value)
+}
+
+// UpdateNoBump replaces key's value without updating the recency of usage of the key.
+// ok specifies if the key was found or not, a missing key is not added.
+func (c *Cache[K, V]) UpdateNoBump(key K, value V) (ok bool) {
+	c.lock.Lock()
+	ok = c.lru.UpdateNoBump(key, value)
+	writes := c.takeWrites()
+	c.lock.Unlock()
+	c.notifyWrites(writes)
+	return ok
+}
+
+// GetMulti returns the values of the present keys and updates their recency of usage
+// in the given order under one lock acquisition, so the last found one becomes the newest.
+// The keys not found are returned in missing.
+func (c *Cache[K, V]) GetMulti(keys []K) (values map[K]V, missing []K) {
+	c.lock.Lock()
+	if c.recencySuspended {
+		values = make(map[K]V, len(keys))
+		for _, key := range keys {
+			if value, ok := c.lru.Peek(key); ok {
+				values[key] = value
+			} else {
+				missing = append(missing, key)
+			}
+		}
+	} else {
+		values, missing = c.lru.GetMulti(keys)
+	}
+	c.hits.Add(uint64(len(keys) - len(missing)))
+	c.misses.Add(uint64(len(missing)))
+	c.window.record(c.now(), uint64(len(keys)-len(missing)), uint64(len(missing)))
+	c.lock.Unlock()
+	for _, key := range keys {
+		_, ok := values[key]
+		c.observeLookup(key, ok)
+	}
+	return values, missing
+}
+
+// TouchMany updates the recency of usage of the present keys in the given order under one lock acquisition,
+// so the last one becomes the newest, returns the number of keys found.
+func (c *Cache[K, V]) TouchMany(keys []K) (touched int) {
+	c.lock.Lock()
+	touched = c.lru.TouchMany(keys)
+	c.lock.Unlock()
+	return touched
+}
+
 // Contains checks if a key exists in the cache without updating the recency of usage.
 func (c *Cache[K, V]) Contains(key K) (ok bool) {
 	c.lock.RLock()
@@ -82,12 +357,39 @@ func (c *Cache[K, V]) Contains(key K) (ok bool) {
 	return ok
 }
 
+// ContainsMany checks which of the keys exist in the cache without updating the recency of usage,
+// returns whether each key was found in the same order as keys.
+func (c *Cache[K, V]) ContainsMany(keys []K) []bool {
+	found := make([]bool, len(keys))
+	c.lock.RLock()
+	for i, key := range keys {
+		found[i] = c.lru.Contains(key)
+	}
+	c.lock.RUnlock()
+	return found
+}
+
+// MissingKeys returns the keys which don't exist in the cache, without updating the recency of usage.
+func (c *Cache[K, V]) MissingKeys(keys []K) []K {
+	var missing []K
+	c.lock.RLock()
+	for _, key := range keys {
+		if !c.lru.Contains(key) {
+			missing = append(missing, key)
+		}
+	}
+	c.lock.RUnlock()
+	return missing
+}
+
 // Peek returns key's value without updating the recency of usage of the key.
 // ok specifies if the key was found or not.
 func (c *Cache[K, V]) Peek(key K) (value V, ok bool) {
 	c.lock.RLock()
 	value, ok = c.lru.Peek(key)
+	c.countLookup(ok)
 	c.lock.RUnlock()
+	c.observeLookup(key, ok)
 	return value, ok
 }
 
@@ -105,17 +407,35 @@ func (c *Cache[K, V]) ContainsOrAdd(key K, value V) (ok, evicted bool) {
 		return true, false
 	}
 	evicted = c.lru.Add(key, value)
-	if evicted && c.onEvict != nil {
+	if evicted {
+		c.evictions.Add(1)
+	}
+	if evicted {
 		k, v = c.evictedKeys[0], c.evictedValues[0]
 		c.evictedKeys, c.evictedValues = c.evictedKeys[:0], c.evictedValues[:0]
 	}
+	writes := c.takeWrites()
+	notifyFullness := c.checkFullness()
 	c.lock.Unlock()
-	if evicted && c.onEvict != nil {
-		c.onEvict(k, v)
+	c.observeAdd(key)
+	c.notifyWrites(writes)
+	if evicted {
+		c.notifyCapacityEvicted(k, v)
+	}
+	if notifyFullness != nil {
+		notifyFullness()
 	}
 	return false, evicted
 }
 
+// AddFirstWins adds an entry to the cache only if the key is missing, so that of
+// concurrent Adds of the same key the first one wins and the rest are no-ops.
+// stored specifies if the value was added or not.
+func (c *Cache[K, V]) AddFirstWins(key K, value V) (stored bool) {
+	ok, _ := c.ContainsOrAdd(key, value)
+	return !ok
+}
+
 // PeekOrAdd checks if a key is in the cache without updating the
 // recency of usage or deleting it for being stale, and if not, adds the value.
 // Returns key's previous value if it was found, whether found and whether an eviction occurred.
@@ -131,17 +451,105 @@ func (c *Cache[K, V]) PeekOrAdd(key K, value V) (prev V, ok, evicted bool) {
 		return prev, ok, false
 	}
 	evicted = c.lru.Add(key, value)
-	if evicted && c.onEvict != nil {
+	if evicted {
+		c.evictions.Add(1)
+	}
+	if evicted {
 		k, v = c.evictedKeys[0], c.evictedValues[0]
 		c.evictedKeys, c.evictedValues = c.evictedKeys[:0], c.evictedValues[:0]
 	}
+	writes := c.takeWrites()
+	notifyFullness := c.checkFullness()
 	c.lock.Unlock()
-	if evicted && c.onEvict != nil {
-		c.onEvict(k, v)
+	c.observeAdd(key)
+	c.notifyWrites(writes)
+	if evicted {
+		c.notifyCapacityEvicted(k, v)
+	}
+	if notifyFullness != nil {
+		notifyFullness()
 	}
 	return prev, ok, evicted
 }
 
+// GetOrAdd returns key's value from the cache and updates the recency of usage of the key.
+// If the key is missing, value is added and returned instead, the lock being held across
+// the lookup and the add. loaded specifies if the key was found or not,
+// evicted if adding the value caused an eviction.
+func (c *Cache[K, V]) GetOrAdd(key K, value V) (actual V, loaded, evicted bool) {
+	var (
+		k K
+		v V
+	)
+	c.lock.Lock()
+	actual, loaded, evicted = c.lru.GetOrAdd(key, value)
+	c.countLookup(loaded)
+	if evicted {
+		c.evictions.Add(1)
+		k, v = c.evictedKeys[0], c.evictedValues[0]
+		c.evictedKeys, c.evictedValues = c.evictedKeys[:0], c.evictedValues[:0]
+	}
+	writes := c.takeWrites()
+	notifyFullness := c.checkFullness()
+	c.lock.Unlock()
+	c.observeLookup(key, loaded)
+	if !loaded {
+		c.observeAdd(key)
+	}
+	c.notifyWrites(writes)
+	if evicted {
+		c.notifyCapacityEvicted(k, v)
+	}
+	if notifyFullness != nil {
+		notifyFullness()
+	}
+	return actual, loaded, evicted
+}
+
+// Compute replaces key's value with the one returned by fn, which gets the current value
+// and whether the key was found. If keep is true, the value is added or updated and the recency
+// of usage of the key is updated, otherwise the key is removed. fn is called with the lock held,
+// so it must not call the cache.
+func (c *Cache[K, V]) Compute(key K, fn func(old V, exists bool) (value V, keep bool)) {
+	var (
+		k                K
+		v                V
+		evicted, removed bool
+	)
+	c.lock.Lock()
+	old, exists := c.lru.Peek(key)
+	value, keep := fn(old, exists)
+	switch {
+	case keep:
+		evicted = c.lru.Add(key, value)
+		if evicted {
+			c.evictions.Add(1)
+		}
+	case exists:
+		removed = c.lru.Remove(key)
+	}
+	if evicted || removed {
+		k, v = c.evictedKeys[0], c.evictedValues[0]
+		c.evictedKeys, c.evictedValues = c.evictedKeys[:0], c.evictedValues[:0]
+	}
+	writes := c.takeWrites()
+	notifyFullness := c.checkFullness()
+	c.lock.Unlock()
+	if keep {
+		c.observeAdd(key)
+	}
+	c.notifyWrites(writes)
+	switch {
+	case evicted:
+		c.notifyCapacityEvicted(k, v)
+	case removed:
+		c.notifyEvicted(k, v)
+	}
+	if notifyFullness != nil {
+		notifyFullness()
+	}
+}
+
 // Remove removes an entry from the cache with the key specified.
 // ok specifies if the key was found or not.
 func (c *Cache[K, V]) Remove(key K) (ok bool) {
@@ -151,17 +559,46 @@ func (c *Cache[K, V]) Remove(key K) (ok bool) {
 	)
 	c.lock.Lock()
 	ok = c.lru.Remove(key)
-	if ok && c.onEvict != nil {
+	if ok {
 		k, v = c.evictedKeys[0], c.evictedValues[0]
 		c.evictedKeys, c.evictedValues = c.evictedKeys[:0], c.evictedValues[:0]
 	}
+	notifyFullness := c.checkFullness()
 	c.lock.Unlock()
-	if ok && c.onEvict != nil {
-		c.onEvict(k, v)
+	if ok {
+		c.notifyEvicted(k, v)
+	}
+	if notifyFullness != nil {
+		notifyFullness()
 	}
 	return ok
 }
 
+// RemoveFunc removes the entries for which predicate returns true under one lock acquisition,
+// returns the number of removed entries. The eviction callback is invoked for each of them,
+// from oldest to newest, after the lock is released. predicate must not call the cache.
+func (c *Cache[K, V]) RemoveFunc(predicate func(key K, value V) bool) (removed int) {
+	var (
+		keys   []K
+		values []V
+	)
+	c.lock.Lock()
+	removed = c.lru.RemoveFunc(predicate)
+	if removed > 0 {
+		keys, values = c.evictedKeys, c.evictedValues
+		c.initEvictBuffers()
+	}
+	notifyFullness := c.checkFullness()
+	c.lock.Unlock()
+	for i := 0; i < len(keys); i++ {
+		c.notifyEvicted(keys[i], values[i])
+	}
+	if notifyFullness != nil {
+		notifyFullness()
+	}
+	return removed
+}
+
 // RemoveOldest removes the oldest entry from the cache.
 func (c *Cache[K, V]) RemoveOldest() (key K, value V, ok bool) {
 	var (
@@ -170,13 +607,17 @@ func (c *Cache[K, V]) RemoveOldest() (key K, value V, ok bool) {
 	)
 	c.lock.Lock()
 	key, value, ok = c.lru.RemoveOldest()
-	if ok && c.onEvict != nil {
+	if ok {
 		k, v = c.evictedKeys[0], c.evictedValues[0]
 		c.evictedKeys, c.evictedValues = c.evictedKeys[:0], c.evictedValues[:0]
 	}
+	notifyFullness := c.checkFullness()
 	c.lock.Unlock()
-	if ok && c.onEvict != nil {
-		c.onEvict(k, v)
+	if ok {
+		c.notifyEvicted(k, v)
+	}
+	if notifyFullness != nil {
+		notifyFullness()
 	}
 	return key, value, ok
 }
@@ -205,6 +646,135 @@ func (c *Cache[K, V]) Values() []V {
 	return values
 }
 
+// Range calls fn for the entries of the cache from oldest to newest without updating
+// the recency of usage, until fn returns false.
+// The read lock is held during the whole iteration, so fn must not call the cache.
+func (c *Cache[K, V]) Range(fn func(key K, value V) bool) {
+	c.lock.RLock()
+	c.lru.Range(fn)
+	c.lock.RUnlock()
+}
+
+// RangeReverse calls fn for the entries of the cache from newest to oldest without updating
+// the recency of usage, until fn returns false.
+// The read lock is held during the whole iteration, so fn must not call the cache.
+func (c *Cache[K, V]) RangeReverse(fn func(key K, value V) bool) {
+	c.lock.RLock()
+	c.lru.RangeReverse(fn)
+	c.lock.RUnlock()
+}
+
+// All returns an iterator over the entries of the cache from oldest to newest,
+// which doesn't update the recency of usage. The entries are copied under the read lock
+// when the iteration starts, so the loop body may call the cache.
+func (c *Cache[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		c.lock.RLock()
+		keys, values := c.lru.Keys(), c.lru.Values()
+		c.lock.RUnlock()
+		for i := range keys {
+			if !yield(keys[i], values[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Reduce folds fn over the entries of the cache from oldest to newest, starting with initial.
+// The read lock is held during the whole fold, so fn must not call the cache.
+func Reduce[K comparable, V any, R any](c *Cache[K, V], initial R, fn func(acc R, key K, value V) R) R {
+	c.lock.RLock()
+	acc := basic_lru.Reduce(c.lru, initial, fn)
+	c.lock.RUnlock()
+	return acc
+}
+
+// CompareAndSwap replaces key's value with new and updates the recency of usage of the key,
+// only if the current value equals old, returns whether the value was replaced.
+// A missing key is not added. It is a function as the values have to be comparable,
+// which the cache doesn't require.
+func CompareAndSwap[K comparable, V comparable](c *Cache[K, V], key K, old, new V) (swapped bool) {
+	c.lock.Lock()
+	if current, ok := c.lru.Peek(key); ok && current == old {
+		swapped = c.lru.Replace(key, new)
+	}
+	writes := c.takeWrites()
+	c.lock.Unlock()
+	c.notifyWrites(writes)
+	return swapped
+}
+
+// Entries returns a copy of the cache contents without updating the recency of usage.
+func (c *Cache[K, V]) Entries() map[K]V {
+	c.lock.RLock()
+	entries := make(map[K]V, c.lru.Len())
+	c.lru.Range(func(key K, value V) bool {
+		entries[key] = value
+		return true
+	})
+	c.lock.RUnlock()
+	return entries
+}
+
+// Clone returns an independent cache of the same size, eviction callback and observer holding the same entries
+// in the same recency order, with their metadata. Eviction listeners, callbacks set later
+// and statistics are not copied.
+func (c *Cache[K, V]) Clone() *Cache[K, V] {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	clone, _ := NewWithOnEvict[K, V](c.lru.Cap(), c.onEvict)
+	clone.observer = c.observer
+	clone.lru.Import(c.lru.Export())
+	return clone
+}
+
+// Export returns the cache entries with their metadata, from oldest to newest.
+func (c *Cache[K, V]) Export() []basic_lru.ExportedEntry[K, V] {
+	c.lock.RLock()
+	entries := c.lru.Export()
+	c.lock.RUnlock()
+	return entries
+}
+
+// Import adds the entries from oldest to newest keeping their metadata,
+// so the entries returned by Export are restored in the same recency order.
+func (c *Cache[K, V]) Import(entries []basic_lru.ExportedEntry[K, V]) {
+	var (
+		keys   []K
+		values []V
+	)
+	c.lock.Lock()
+	evicted := c.lru.Import(entries)
+	c.evictions.Add(uint64(evicted))
+	if evicted > 0 {
+		keys, values = c.evictedKeys, c.evictedValues
+		c.initEvictBuffers()
+	}
+	writes := c.takeWrites()
+	notifyFullness := c.checkFullness()
+	c.lock.Unlock()
+	for _, e := range entries {
+		c.observeAdd(e.Key)
+	}
+	c.notifyWrites(writes)
+	for i := 0; i < len(keys); i++ {
+		c.notifyCapacityEvicted(keys[i], values[i])
+	}
+	if notifyFullness != nil {
+		notifyFullness()
+	}
+}
+
+// LowestScoring returns the entry with the lowest score, computed by the score function from the entry
+// and its metadata. It scans all the entries under the read lock, so score must not call the cache.
+// ok is false if the cache is empty.
+func (c *Cache[K, V]) LowestScoring(score func(key K, value V, lastAccess time.Time, accessCount uint64) float64) (key K, value V, ok bool) {
+	c.lock.RLock()
+	key, value, ok = c.lru.LowestScoring(score)
+	c.lock.RUnlock()
+	return key, value, ok
+}
+
 // Len returns the number of entries in the cache.
 func (c *Cache[K, V]) Len() int {
 	c.lock.RLock()
@@ -218,7 +788,7 @@ func (c *Cache[K, V]) Cap() int {
 	return c.lru.Cap()
 }
 
-// Purge clears all the cache entries.
+// Purge clears all the cache entries, invoking the eviction callback from oldest to newest.
 func (c *Cache[K, V]) Purge() {
 	var (
 		keys   []K
@@ -226,35 +796,77 @@ func (c *Cache[K, V]) Purge() {
 	)
 	c.lock.Lock()
 	c.lru.Purge()
-	if c.onEvict != nil && len(c.evictedKeys) > 0 {
+	if len(c.evictedKeys) > 0 {
 		keys, values = c.evictedKeys, c.evictedValues
 		c.initEvictBuffers()
 	}
+	notifyFullness := c.checkFullness()
 	c.lock.Unlock()
-	if c.onEvict != nil {
-		for i := 0; i < len(keys); i++ {
-			c.onEvict(keys[i], values[i])
-		}
+	for i := 0; i < len(keys); i++ {
+		c.notifyEvicted(keys[i], values[i])
+	}
+	if notifyFullness != nil {
+		notifyFullness()
 	}
 }
 
+// DrainAll removes all the cache entries and returns them in one step,
+// so no entry added concurrently is lost. The eviction callback is not invoked
+// as the caller takes ownership of the entries.
+func (c *Cache[K, V]) DrainAll() map[K]V {
+	c.lock.Lock()
+	entries := c.lru.Drain()
+	notifyFullness := c.checkFullness()
+	c.lock.Unlock()
+	if notifyFullness != nil {
+		notifyFullness()
+	}
+	return entries
+}
+
+// Rotate moves all the cache entries to a new non-thread safe cache in one step, leaving the cache empty.
+// The eviction callback is not invoked as the caller takes ownership of the entries.
+func (c *Cache[K, V]) Rotate() *basic_lru.LRU[K, V] {
+	c.lock.Lock()
+	rotated := c.lru.Rotate()
+	notifyFullness := c.checkFullness()
+	c.lock.Unlock()
+	if notifyFullness != nil {
+		notifyFullness()
+	}
+	return rotated
+}
+
 // Resize changes the cache size, returning number of evicted entries.
+// Concurrent calls are applied one after another, each evicting down to its own size,
+// so the cache ends up with the size of the last one.
 func (c *Cache[K, V]) Resize(size int) (evicted int) {
+	_, evicted = c.ResizeTo(size)
+	return evicted
+}
+
+// ResizeTo changes the cache size, returning the number of entries left in the cache
+// and the number of evicted entries, both captured under the same lock acquisition.
+func (c *Cache[K, V]) ResizeTo(size int) (finalLen, evicted int) {
 	var (
 		keys   []K
 		values []V
 	)
 	c.lock.Lock()
 	evicted = c.lru.Resize(size)
-	if evicted > 0 && c.onEvict != nil {
+	finalLen = c.lru.Len()
+	c.evictions.Add(uint64(evicted))
+	if evicted > 0 {
 		keys, values = c.evictedKeys, c.evictedValues
 		c.initEvictBuffers()
 	}
+	notifyFullness := c.checkFullness()
 	c.lock.Unlock()
-	if evicted > 0 && c.onEvict != nil {
-		for i := 0; i < len(keys); i++ {
-			c.onEvict(keys[i], values[i])
-		}
+	for i := 0; i < len(keys); i++ {
+		c.notifyEvicted(keys[i], values[i])
 	}
-	return evicted
+	if notifyFullness != nil {
+		notifyFullness()
+	}
+	return finalLen, evicted
 }
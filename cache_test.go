@@ -0,0 +1,770 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPurgeOrder(t *testing.T) {
+	var evicted []int
+	c, err := NewWithOnEvict[int, int](5, func(key, value int) {
+		evicted = append(evicted, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []int{3, 1, 4, 5, 2} {
+		c.Add(key, key)
+	}
+	c.Get(5)
+	keys := c.Keys()
+
+	c.Purge()
+	if !slices.Equal(evicted, keys) {
+		t.Fatalf("evicted %v, want the order of Keys %v", evicted, keys)
+	}
+}
+
+func TestReduce(t *testing.T) {
+	type queue struct {
+		name  string
+		depth int
+	}
+	c, err := New[string, queue](4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add("a", queue{name: "a", depth: 3})
+	c.Add("b", queue{name: "b", depth: 4})
+	c.Add("c", queue{name: "c", depth: 5})
+
+	total := Reduce(c, 0, func(acc int, key string, value queue) int {
+		return acc + value.depth
+	})
+	if total != 12 {
+		t.Fatalf("total depth = %d, want 12", total)
+	}
+	// the entries are folded from oldest to newest
+	names := Reduce(c, "", func(acc string, key string, value queue) string {
+		return acc + value.name
+	})
+	if names != "abc" {
+		t.Fatalf("fold order = %q, want %q", names, "abc")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	c, err := New[int, int](2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(1, 1)
+	c.Add(2, 2)
+	if !c.Replace(1, 10) {
+		t.Fatal("Replace of a present key = false, want true")
+	}
+	// 1 was promoted, so 2 is the oldest
+	c.Add(3, 3)
+	if value, ok := c.Peek(1); !ok || value != 10 {
+		t.Fatalf("Peek(1) = %d, %v, want 10, true", value, ok)
+	}
+	if c.Contains(2) {
+		t.Fatal("key 2 should have been evicted instead of the replaced key")
+	}
+	if c.Replace(4, 4) || c.Contains(4) {
+		t.Fatal("Replace of an absent key added it")
+	}
+}
+
+func TestDrainAllConcurrentAdd(t *testing.T) {
+	const writers, perWriter = 4, 1000
+	var evicted atomic.Int64
+	c, err := NewWithOnEvict[int, int](writers*perWriter, func(key, value int) {
+		evicted.Add(1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				key := w*perWriter + i
+				c.Add(key, key)
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	drained := make(map[int]int)
+	collect := func() {
+		for key, value := range c.DrainAll() {
+			if _, ok := drained[key]; ok {
+				t.Fatalf("key %d drained twice", key)
+			}
+			drained[key] = value
+		}
+	}
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		collect()
+	}
+	collect()
+
+	if len(drained) != writers*perWriter {
+		t.Fatalf("drained %d entries, want %d", len(drained), writers*perWriter)
+	}
+	for key, value := range drained {
+		if key != value {
+			t.Fatalf("drained %d: %d", key, value)
+		}
+	}
+	if n := evicted.Load(); n != 0 {
+		t.Fatalf("DrainAll fired onEvict %d times, want 0", n)
+	}
+	if c.Len() != 0 {
+		t.Fatalf("len after DrainAll = %d, want 0", c.Len())
+	}
+}
+
+func TestAddFirstWinsConcurrent(t *testing.T) {
+	c, err := New[string, int](8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const writers = 16
+	var (
+		stored atomic.Int32
+		winner atomic.Int32
+		wg     sync.WaitGroup
+		start  = make(chan struct{})
+	)
+	for w := 1; w <= writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if c.AddFirstWins("k", w) {
+				stored.Add(1)
+				winner.Store(int32(w))
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if n := stored.Load(); n != 1 {
+		t.Fatalf("%d writers stored, want 1", n)
+	}
+	if value, _ := c.Peek("k"); value != int(winner.Load()) {
+		t.Fatalf("value = %d, want the first writer's %d", value, winner.Load())
+	}
+}
+
+func TestGetOrZero(t *testing.T) {
+	c, err := New[string, int](2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add("a", 1)
+	if value, existed := c.GetOrZero("a"); !existed || value != 1 {
+		t.Fatalf("GetOrZero of a present key = %d, %v, want 1, true", value, existed)
+	}
+	if value, existed := c.GetOrZero("b"); existed || value != 0 {
+		t.Fatalf("GetOrZero of a missing key = %d, %v, want 0, false", value, existed)
+	}
+	if value, ok := c.Get("b"); !ok || value != 0 {
+		t.Fatalf("Get after GetOrZero = %d, %v, want a hit of 0", value, ok)
+	}
+	if value, existed := c.GetOrZero("b"); !existed || value != 0 {
+		t.Fatalf("second GetOrZero = %d, %v, want 0, true", value, existed)
+	}
+	// the read added an entry, so a full cache evicts
+	c.GetOrZero("c")
+	if c.Contains("a") || c.Len() != 2 {
+		t.Fatalf("GetOrZero of a full cache did not evict the oldest key, keys %v", c.Keys())
+	}
+}
+
+func TestRotate(t *testing.T) {
+	evicted := 0
+	c, err := NewWithOnEvict[int, string](3, func(key int, value string) {
+		evicted++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(1, "one")
+	c.Add(2, "two")
+	c.Add(3, "three")
+	c.Get(1)
+
+	rotated := c.Rotate()
+	if c.Len() != 0 {
+		t.Fatalf("len after Rotate = %d, want 0", c.Len())
+	}
+	if keys := rotated.Keys(); !slices.Equal(keys, []int{2, 3, 1}) {
+		t.Fatalf("rotated keys = %v, want [2 3 1]", keys)
+	}
+	if values := rotated.Values(); !slices.Equal(values, []string{"two", "three", "one"}) {
+		t.Fatalf("rotated values = %v, want [two three one]", values)
+	}
+	if evicted != 0 {
+		t.Fatalf("Rotate fired onEvict %d times, want 0", evicted)
+	}
+
+	// the receiver is ready for the next window
+	c.Add(4, "four")
+	if keys := c.Keys(); !slices.Equal(keys, []int{4}) {
+		t.Fatalf("keys after Rotate and Add = %v, want [4]", keys)
+	}
+	if rotated.Contains(4) {
+		t.Fatal("the rotated cache shares entries with the receiver")
+	}
+}
+
+func TestContainsMany(t *testing.T) {
+	c, err := New[int, int](4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(1, 1)
+	c.Add(3, 3)
+	keys := []int{1, 2, 3, 4}
+	if got := c.ContainsMany(keys); !slices.Equal(got, []bool{true, false, true, false}) {
+		t.Fatalf("ContainsMany = %v, want [true false true false]", got)
+	}
+	if got := c.MissingKeys(keys); !slices.Equal(got, []int{2, 4}) {
+		t.Fatalf("MissingKeys = %v, want [2 4]", got)
+	}
+	if got := c.MissingKeys(nil); len(got) != 0 {
+		t.Fatalf("MissingKeys of no keys = %v, want none", got)
+	}
+}
+
+func TestCompute(t *testing.T) {
+	c, err := New[string, int](3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add("a", 1)
+	c.Add("b", 2)
+
+	increment := func(old int, exists bool) (int, bool) {
+		return old + 10, true
+	}
+	c.Compute("a", increment)
+	if value, ok := c.Peek("a"); !ok || value != 11 {
+		t.Fatalf("updated value = %d, %v, want 11, true", value, ok)
+	}
+	if keys := c.Keys(); !slices.Equal(keys, []string{"b", "a"}) {
+		t.Fatalf("keys after update = %v, want [b a] as the key is promoted", keys)
+	}
+
+	var sawExists bool
+	c.Compute("c", func(old int, exists bool) (int, bool) {
+		sawExists = exists
+		return increment(old, exists)
+	})
+	if value, ok := c.Peek("c"); sawExists || !ok || value != 10 {
+		t.Fatalf("inserted value = %d, %v (exists %v), want 10, true (exists false)", value, ok, sawExists)
+	}
+
+	c.Compute("b", func(old int, exists bool) (int, bool) {
+		return old, false
+	})
+	if c.Contains("b") {
+		t.Fatal("Compute keeping false did not remove the key")
+	}
+	// removing a missing key does nothing
+	c.Compute("d", func(old int, exists bool) (int, bool) {
+		return 0, false
+	})
+	if keys := c.Keys(); !slices.Equal(keys, []string{"a", "c"}) {
+		t.Fatalf("keys = %v, want [a c]", keys)
+	}
+}
+
+func TestSuspendRecency(t *testing.T) {
+	c, err := New[int, int](5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		c.Add(i, i*10)
+	}
+	before := c.Keys()
+
+	c.SuspendRecency()
+	for range 3 {
+		for i := 0; i < 5; i++ {
+			if value, ok := c.Get(i); !ok || value != i*10 {
+				t.Fatalf("Get(%d) while suspended = %d, %v, want %d, true", i, value, ok, i*10)
+			}
+			if keys := c.Keys(); !slices.Equal(keys, before) {
+				t.Fatalf("keys changed to %v while recency is suspended, want %v", keys, before)
+			}
+		}
+	}
+	if hits := c.Stats().Hits; hits != 15 {
+		t.Fatalf("hits while suspended = %d, want 15", hits)
+	}
+
+	c.ResumeRecency()
+	c.Get(0)
+	if keys := c.Keys(); !slices.Equal(keys, []int{1, 2, 3, 4, 0}) {
+		t.Fatalf("keys after ResumeRecency and Get = %v, want [1 2 3 4 0]", keys)
+	}
+}
+
+func TestGetAndDemote(t *testing.T) {
+	c, err := New[int, int](3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(1, 1)
+	c.Add(2, 2)
+	c.Add(3, 3)
+	if value, ok := c.GetAndDemote(2); !ok || value != 2 {
+		t.Fatalf("GetAndDemote(2) = %d, %v, want 2, true", value, ok)
+	}
+	c.Add(4, 4)
+	if c.Contains(2) || !c.Contains(1) {
+		t.Fatalf("keys = %v, want the demoted key evicted before the untouched ones", c.Keys())
+	}
+	if stats := c.Stats(); stats.Hits != 1 {
+		t.Fatalf("hits = %d, want GetAndDemote counted as a lookup", stats.Hits)
+	}
+}
+
+func TestResizeToConcurrent(t *testing.T) {
+	const initial = 100
+	c, err := New[int, int](initial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < initial; i++ {
+		c.Add(i, i)
+	}
+
+	sizes := []int{90, 40, 70, 55, 80, 65}
+	var (
+		wg      sync.WaitGroup
+		evicted atomic.Int64
+	)
+	for _, size := range sizes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			finalLen, n := c.ResizeTo(size)
+			if finalLen > size {
+				t.Errorf("ResizeTo(%d) left %d entries", size, finalLen)
+			}
+			evicted.Add(int64(n))
+		}()
+	}
+	wg.Wait()
+
+	// the calls are applied one after another: entries are only evicted, down to the smallest size,
+	// and the size is the one of the last call
+	if !slices.Contains(sizes, c.Cap()) {
+		t.Fatalf("size after concurrent resizes = %d, want one of %v", c.Cap(), sizes)
+	}
+	if c.Len() != slices.Min(sizes) {
+		t.Fatalf("len after concurrent resizes = %d, want %d", c.Len(), slices.Min(sizes))
+	}
+	if n := evicted.Load(); n != initial-int64(slices.Min(sizes)) {
+		t.Fatalf("evicted %d entries in total, want %d", n, initial-slices.Min(sizes))
+	}
+
+	if finalLen, n := c.ResizeTo(30); finalLen != 30 || n != 10 || c.Cap() != 30 {
+		t.Fatalf("ResizeTo(30) = %d, %d with size %d, want 30, 10 with size 30", finalLen, n, c.Cap())
+	}
+}
+
+func TestTouchMany(t *testing.T) {
+	c, err := New[int, int](5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		c.Add(i, i)
+	}
+	if touched := c.TouchMany([]int{3, 9, 0, 1}); touched != 3 {
+		t.Fatalf("TouchMany = %d, want 3 as key 9 is missing", touched)
+	}
+	if keys := c.Keys(); !slices.Equal(keys, []int{2, 4, 3, 0, 1}) {
+		t.Fatalf("keys = %v, want [2 4 3 0 1] with the last touched key the newest", keys)
+	}
+	if c.Contains(9) {
+		t.Fatal("TouchMany added a missing key")
+	}
+}
+
+func TestGetOrAdd(t *testing.T) {
+	var evicted []int
+	c, err := NewWithOnEvict[int, string](1, func(key int, value string) {
+		evicted = append(evicted, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, loaded, ev := c.GetOrAdd(1, "one"); actual != "one" || loaded || ev {
+		t.Fatalf("GetOrAdd of a missing key = %q, %v, %v, want one, false, false", actual, loaded, ev)
+	}
+	if actual, loaded, _ := c.GetOrAdd(1, "uno"); actual != "one" || !loaded {
+		t.Fatalf("GetOrAdd of a present key = %q, %v, want the existing one, true", actual, loaded)
+	}
+	if _, _, ev := c.GetOrAdd(2, "two"); !ev || !slices.Equal(evicted, []int{1}) {
+		t.Fatalf("GetOrAdd evicted %v, callback got %v, want true, [1]", ev, evicted)
+	}
+	if stats := c.Stats(); stats.Hits != 1 || stats.Misses != 2 || stats.Evictions != 1 {
+		t.Fatalf("stats = %+v, want 1 hit, 2 misses and 1 eviction", stats)
+	}
+}
+
+func TestGetOrAddConcurrent(t *testing.T) {
+	c, err := New[string, int](4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const callers = 16
+	var (
+		wg     sync.WaitGroup
+		stored atomic.Int32
+		start  = make(chan struct{})
+		actual = make(chan int, callers)
+	)
+	for i := 1; i <= callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			value, loaded, _ := c.GetOrAdd("k", i)
+			if !loaded {
+				stored.Add(1)
+			}
+			actual <- value
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(actual)
+	if n := stored.Load(); n != 1 {
+		t.Fatalf("%d callers added the key, want 1", n)
+	}
+	want, _ := c.Peek("k")
+	for value := range actual {
+		if value != want {
+			t.Fatalf("caller got %d, want the stored %d", value, want)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	c, err := New[int, int](3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		c.Add(i, i)
+	}
+	var keys []int
+	c.Range(func(key, value int) bool {
+		keys = append(keys, key)
+		return key != 2
+	})
+	if !slices.Equal(keys, []int{1, 2}) {
+		t.Fatalf("Range visited %v, want [1 2]", keys)
+	}
+	keys = nil
+	c.RangeReverse(func(key, value int) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if !slices.Equal(keys, []int{3, 2, 1}) {
+		t.Fatalf("RangeReverse visited %v, want [3 2 1]", keys)
+	}
+}
+
+func TestAll(t *testing.T) {
+	c, err := New[int, int](3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		c.Add(i, i*i)
+	}
+	c.Get(1)
+
+	entries := maps.Collect(c.All())
+	if len(entries) != 3 || entries[2] != 4 {
+		t.Fatalf("collected %v, want 3 entries", entries)
+	}
+	var keys []int
+	for key := range c.All() {
+		keys = append(keys, key)
+		// the entries are copied, so the body may change the cache
+		c.Remove(key)
+	}
+	if !slices.Equal(keys, []int{2, 3, 1}) {
+		t.Fatalf("All yielded %v, want the order of Keys [2 3 1]", keys)
+	}
+	if c.Len() != 0 {
+		t.Fatalf("len = %d, want 0", c.Len())
+	}
+}
+
+func TestGetMulti(t *testing.T) {
+	c, err := New[int, int](3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		c.Add(i, i)
+	}
+	values, missing := c.GetMulti([]int{0, 5, 1})
+	if !maps.Equal(values, map[int]int{0: 0, 1: 1}) || !slices.Equal(missing, []int{5}) {
+		t.Fatalf("GetMulti = %v, %v, want 0 and 1 found, [5] missing", values, missing)
+	}
+	if keys := c.Keys(); !slices.Equal(keys, []int{2, 0, 1}) {
+		t.Fatalf("keys = %v, want [2 0 1]", keys)
+	}
+	if stats := c.Stats(); stats.Hits != 2 || stats.Misses != 1 {
+		t.Fatalf("stats = %+v, want 2 hits and 1 miss", stats)
+	}
+}
+
+func TestAddMulti(t *testing.T) {
+	var (
+		evicted []int
+		c       *Cache[int, int]
+	)
+	c, err := NewWithOnEvict[int, int](4, func(key, value int) {
+		// the callback is invoked after the lock is released, so it can call the cache
+		c.Len()
+		evicted = append(evicted, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := make(map[int]int)
+	for i := 0; i < 10; i++ {
+		entries[i] = i
+	}
+	if n := c.AddMulti(entries); n != 6 {
+		t.Fatalf("AddMulti evicted %d entries, want the overflow of 6", n)
+	}
+	if len(evicted) != 6 {
+		t.Fatalf("callback invoked for %v, want 6 evicted keys", evicted)
+	}
+	for _, key := range evicted {
+		if c.Contains(key) {
+			t.Fatalf("evicted key %d is in the cache", key)
+		}
+	}
+	if c.Len() != 4 || c.Stats().Evictions != 6 || c.Stats().Insertions != 10 {
+		t.Fatalf("len %d, stats %+v, want 4 entries, 6 evictions and 10 insertions", c.Len(), c.Stats())
+	}
+}
+
+func TestRemoveFunc(t *testing.T) {
+	var evicted []string
+	c, err := NewWithOnEvict[string, int](10, func(key string, value int) {
+		evicted = append(evicted, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range []string{"t1/a", "t2/a", "t1/b", "t2/b"} {
+		c.Add(key, i)
+	}
+	// log out tenant t1
+	removed := c.RemoveFunc(func(key string, value int) bool {
+		return key[:3] == "t1/"
+	})
+	if removed != 2 || !slices.Equal(evicted, []string{"t1/a", "t1/b"}) {
+		t.Fatalf("RemoveFunc = %d, evicted %v, want 2, [t1/a t1/b]", removed, evicted)
+	}
+	if keys := c.Keys(); !slices.Equal(keys, []string{"t2/a", "t2/b"}) {
+		t.Fatalf("keys = %v, want [t2/a t2/b]", keys)
+	}
+}
+
+func TestEntries(t *testing.T) {
+	c, err := New[int, string](3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(1, "one")
+	c.Add(2, "two")
+	entries := c.Entries()
+	if !maps.Equal(entries, map[int]string{1: "one", 2: "two"}) {
+		t.Fatalf("Entries = %v", entries)
+	}
+	// the copy is independent and reading it doesn't update the recency of usage
+	entries[3] = "three"
+	if c.Contains(3) {
+		t.Fatal("changing the copy changed the cache")
+	}
+	if keys := c.Keys(); !slices.Equal(keys, []int{1, 2}) {
+		t.Fatalf("keys = %v, want [1 2]", keys)
+	}
+}
+
+func TestClone(t *testing.T) {
+	c, err := New[int, int](3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		c.Add(i, i)
+	}
+	c.Get(1)
+
+	clone := c.Clone()
+	if !slices.Equal(clone.Keys(), c.Keys()) || !slices.Equal(clone.Values(), c.Values()) || clone.Cap() != c.Cap() {
+		t.Fatalf("clone holds %v of size %d, want %v of size %d", clone.Keys(), clone.Cap(), c.Keys(), c.Cap())
+	}
+
+	clone.Add(4, 4)
+	clone.Add(1, 10)
+	clone.Remove(3)
+	clone.Resize(5)
+	if keys := c.Keys(); !slices.Equal(keys, []int{2, 3, 1}) || c.Cap() != 3 {
+		t.Fatalf("original keys = %v with size %d after changing the clone, want [2 3 1] and 3", keys, c.Cap())
+	}
+	if value, _ := c.Peek(1); value != 1 {
+		t.Fatalf("original value = %d, want 1", value)
+	}
+	if keys := clone.Keys(); !slices.Equal(keys, []int{4, 1}) {
+		t.Fatalf("clone keys = %v, want [4 1]", keys)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	evicted := 0
+	c, err := NewWithOnEvict[string, int](2, func(key string, value int) {
+		evicted++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add("a", 1)
+	c.Add("b", 2)
+	if !c.UpdateNoBump("a", 10) || !c.Update("b", 20) {
+		t.Fatal("update of a present key = false")
+	}
+	if keys := c.Keys(); !slices.Equal(keys, []string{"a", "b"}) {
+		t.Fatalf("keys = %v, want [a b]", keys)
+	}
+	if c.Update("c", 3) || c.UpdateNoBump("c", 3) || c.Contains("c") {
+		t.Fatal("update of an absent key added it")
+	}
+	if value, _ := c.Peek("a"); value != 10 || evicted != 0 {
+		t.Fatalf("a = %d with %d evictions, want 10 and none", value, evicted)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	c, _ := New[string, int](4)
+	c.Add("a", 1)
+	c.Add("b", 2)
+
+	tests := []struct {
+		key      string
+		old, new int
+		swapped  bool
+		want     int
+		present  bool
+	}{
+		{key: "a", old: 1, new: 10, swapped: true, want: 10, present: true},
+		{key: "b", old: 1, new: 20, swapped: false, want: 2, present: true},
+		{key: "c", old: 0, new: 30, swapped: false, present: false},
+	}
+	for _, tt := range tests {
+		if swapped := CompareAndSwap(c, tt.key, tt.old, tt.new); swapped != tt.swapped {
+			t.Fatalf("CompareAndSwap(%q, %d, %d) = %v, want %v", tt.key, tt.old, tt.new, swapped, tt.swapped)
+		}
+		if value, ok := c.Peek(tt.key); ok != tt.present || value != tt.want {
+			t.Fatalf("Peek(%q) = %d, %v, want %d, %v", tt.key, value, ok, tt.want, tt.present)
+		}
+	}
+	// the swap bumped a, the mismatch left b alone
+	if keys := c.Keys(); !slices.Equal(keys, []string{"b", "a"}) {
+		t.Fatalf("keys = %v, want [b a]", keys)
+	}
+}
+
+func TestCompareAndSwapConcurrent(t *testing.T) {
+	c, _ := New[string, int](4)
+	c.Add("n", 0)
+	const goroutines, increments = 8, 100
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range increments {
+				for {
+					n, _ := c.Peek("n")
+					if CompareAndSwap(c, "n", n, n+1) {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n, _ := c.Peek("n"); n != goroutines*increments {
+		t.Fatalf("n = %d, want %d", n, goroutines*increments)
+	}
+}
+
+func TestOnAddOnUpdate(t *testing.T) {
+	var adds, updates []string
+	var c *Cache[string, int]
+	c, err := NewWithCallbacks(2, nil,
+		func(key string, value int) {
+			// the callbacks are invoked outside the lock
+			if !c.Contains(key) {
+				t.Errorf("added key %s is not in the cache", key)
+			}
+			adds = append(adds, key)
+		},
+		func(key string, oldValue, newValue int) {
+			if oldValue+1 != newValue {
+				t.Errorf("update of %s from %d to %d, want the next value", key, oldValue, newValue)
+			}
+			updates = append(updates, key)
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add("a", 1)
+	c.Add("b", 1)
+	c.Add("a", 2)
+	c.Add("c", 1) // evicts b
+	c.Add("b", 1)
+
+	if !slices.Equal(adds, []string{"a", "b", "c", "b"}) {
+		t.Fatalf("adds %v, want [a b c b]", adds)
+	}
+	if !slices.Equal(updates, []string{"a"}) {
+		t.Fatalf("updates %v, want [a]", updates)
+	}
+}
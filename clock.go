@@ -0,0 +1,31 @@
+package main
+
+import "time"
+
+// clock tells the time of a cache and runs its timers, replaced by tests to move it on at will.
+type clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) timer
+}
+
+// timer is a timer started by clock.AfterFunc, like a time.Timer.
+type timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// now returns the current time of the cache clock, the real time unless one is set.
+func (c *Cache[K, V]) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
+
+// afterFunc calls f in its own goroutine after d has passed on the cache clock, like time.AfterFunc.
+func (c *Cache[K, V]) afterFunc(d time.Duration, f func()) timer {
+	if c.clock == nil {
+		return time.AfterFunc(d, f)
+	}
+	return c.clock.AfterFunc(d, f)
+}
@@ -0,0 +1,65 @@
+package main
+
+import "sync"
+
+// KeySimilarity returns the Jaccard similarity |A∩B|/|A∪B| of the key sets of two caches,
+// 1 meaning the same keys and 0 no common keys. Two empty caches are considered equal.
+// Each key set is snapshotted under its own cache lock, a before b.
+func KeySimilarity[K comparable, V any](a, b *Cache[K, V]) float64 {
+	if a == b {
+		return 1
+	}
+	keysA := a.Keys()
+	keysB := b.Keys()
+	if len(keysA) == 0 && len(keysB) == 0 {
+		return 1
+	}
+	set := make(map[K]struct{}, len(keysA))
+	for _, key := range keysA {
+		set[key] = struct{}{}
+	}
+	common := 0
+	for _, key := range keysB {
+		if _, ok := set[key]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(keysA)+len(keysB)-common)
+}
+
+// MissingIn returns the keys of source which don't exist in target, from oldest to newest in source.
+// The keys of source are snapshotted under its lock, then looked up under the lock of target,
+// without updating the recency of usage in either cache.
+func MissingIn[K comparable, V any](source, target *Cache[K, V]) []K {
+	if source == target {
+		return nil
+	}
+	return target.MissingKeys(source.Keys())
+}
+
+// swapLock serializes Swap calls, so that two of them never take the locks of the same caches
+// in opposite order.
+var swapLock sync.Mutex
+
+// Swap exchanges the entries and the sizes of two caches under both their locks, so readers of
+// either see them before or after the swap. Each cache keeps its callbacks and statistics.
+func Swap[K comparable, V any](a, b *Cache[K, V]) {
+	if a == b {
+		return
+	}
+	swapLock.Lock()
+	a.lock.Lock()
+	b.lock.Lock()
+	a.lru.SwapContents(b.lru)
+	notifyA := a.checkFullness()
+	notifyB := b.checkFullness()
+	b.lock.Unlock()
+	a.lock.Unlock()
+	swapLock.Unlock()
+	if notifyA != nil {
+		notifyA()
+	}
+	if notifyB != nil {
+		notifyB()
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+// newCacheWithKeys returns a cache holding the given keys.
+func newCacheWithKeys(t *testing.T, keys ...int) *Cache[int, int] {
+	t.Helper()
+	c, err := New[int, int](16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		c.Add(key, key)
+	}
+	return c
+}
+
+func TestKeySimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []int
+		want float64
+	}{
+		{"partial overlap", []int{1, 2, 3, 4}, []int{3, 4, 5, 6, 7, 8}, 2.0 / 8},
+		{"subset", []int{1, 2}, []int{1, 2, 3, 4}, 0.5},
+		{"same keys", []int{1, 2, 3}, []int{3, 2, 1}, 1},
+		{"disjoint", []int{1, 2}, []int{3}, 0},
+		{"one empty", nil, []int{1}, 0},
+		{"both empty", nil, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := newCacheWithKeys(t, tt.a...), newCacheWithKeys(t, tt.b...)
+			if got := KeySimilarity(a, b); got != tt.want {
+				t.Fatalf("KeySimilarity = %v, want %v", got, tt.want)
+			}
+			if got := KeySimilarity(b, a); got != tt.want {
+				t.Fatalf("KeySimilarity with swapped caches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	c := newCacheWithKeys(t, 1)
+	if got := KeySimilarity(c, c); got != 1 {
+		t.Fatalf("KeySimilarity of a cache with itself = %v, want 1", got)
+	}
+}
+
+func TestMissingIn(t *testing.T) {
+	source := newCacheWithKeys(t, 1, 2, 3, 4, 5)
+	target := newCacheWithKeys(t, 2, 4, 6)
+	if missing := MissingIn(source, target); !slices.Equal(missing, []int{1, 3, 5}) {
+		t.Fatalf("MissingIn = %v, want [1 3 5]", missing)
+	}
+	if missing := MissingIn(target, source); !slices.Equal(missing, []int{6}) {
+		t.Fatalf("MissingIn the other way = %v, want [6]", missing)
+	}
+	if missing := MissingIn(source, source); len(missing) != 0 {
+		t.Fatalf("MissingIn of a cache in itself = %v, want none", missing)
+	}
+	// the lookups don't update the recency of usage
+	if keys := target.Keys(); !slices.Equal(keys, []int{2, 4, 6}) {
+		t.Fatalf("target keys = %v, want [2 4 6]", keys)
+	}
+}
+
+func TestSwap(t *testing.T) {
+	var evictedFromLive []int
+	live, err := NewWithOnEvict[int, int](3, func(key, value int) {
+		evictedFromLive = append(evictedFromLive, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		live.Add(i, i)
+	}
+	replacement, err := New[int, int](5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replacement.Add(10, 10)
+	replacement.Add(11, 11)
+
+	Swap(live, replacement)
+	if keys := live.Keys(); !slices.Equal(keys, []int{10, 11}) || live.Cap() != 5 {
+		t.Fatalf("live holds %v of size %d, want [10 11] of size 5", keys, live.Cap())
+	}
+	if keys := replacement.Keys(); !slices.Equal(keys, []int{1, 2, 3}) || replacement.Cap() != 3 {
+		t.Fatalf("replacement holds %v of size %d, want [1 2 3] of size 3", keys, replacement.Cap())
+	}
+	// the caches keep their callbacks
+	replacement.Add(4, 4)
+	for i := 12; i <= 15; i++ {
+		live.Add(i, i)
+	}
+	if !slices.Equal(evictedFromLive, []int{10}) {
+		t.Fatalf("live eviction callback got %v, want [10]", evictedFromLive)
+	}
+
+	Swap(live, live)
+	if live.Len() != 5 {
+		t.Fatalf("swapping a cache with itself changed it to %v", live.Keys())
+	}
+}
+
+func TestSwapConcurrent(t *testing.T) {
+	a, b := newCacheWithKeys(t, 1), newCacheWithKeys(t, 2, 3)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				// opposite argument orders must not deadlock
+				if i%2 == 0 {
+					Swap(a, b)
+				} else {
+					Swap(b, a)
+				}
+				if n := a.Len(); n != 1 && n != 2 {
+					t.Errorf("a holds %d entries, want the ones of either cache", n)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if a.Len()+b.Len() != 3 {
+		t.Fatalf("caches hold %d entries in total, want 3", a.Len()+b.Len())
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// debounced is a value waiting for the quiet period of its key to pass before being added.
+type debounced[V any] struct {
+	value V
+	timer timer
+}
+
+// debouncer holds the values of AddDebounced until they are added.
+type debouncer[K comparable, V any] struct {
+	lock    sync.Mutex
+	pending map[K]*debounced[V]
+}
+
+// AddDebounced adds the value after quiet has passed without another AddDebounced of the key,
+// so of rapid updates of a key only the last one is added. The value is added with Add
+// from a timer goroutine, until then Get doesn't return it. Flush adds the pending values at once.
+func (c *Cache[K, V]) AddDebounced(key K, value V, quiet time.Duration) {
+	d := &c.debouncer
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if p, ok := d.pending[key]; ok && p.timer.Stop() {
+		p.value = value
+		p.timer.Reset(quiet)
+		return
+	}
+	// a fired timer waiting for the lock finds a new pending value and leaves it
+	if d.pending == nil {
+		d.pending = make(map[K]*debounced[V])
+	}
+	p := &debounced[V]{value: value}
+	p.timer = c.afterFunc(quiet, func() {
+		d.lock.Lock()
+		if d.pending[key] != p {
+			d.lock.Unlock()
+			return
+		}
+		delete(d.pending, key)
+		d.lock.Unlock()
+		c.Add(key, p.value)
+	})
+	d.pending[key] = p
+}
+
+// Flush adds the values pending in AddDebounced right away, returns the number of added values.
+func (c *Cache[K, V]) Flush() (flushed int) {
+	d := &c.debouncer
+	d.lock.Lock()
+	entries := make(map[K]V, len(d.pending))
+	for key, p := range d.pending {
+		p.timer.Stop()
+		entries[key] = p.value
+	}
+	clear(d.pending)
+	d.lock.Unlock()
+	if len(entries) > 0 {
+		c.AddMulti(entries)
+	}
+	return len(entries)
+}
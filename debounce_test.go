@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddDebounced(t *testing.T) {
+	var adds []int
+	c, err := NewWithCallbacks(4, nil,
+		func(key string, value int) {
+			adds = append(adds, value)
+		},
+		func(key string, oldValue, newValue int) {
+			t.Errorf("update of %s from %d to %d, want a single add", key, oldValue, newValue)
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock()
+	c.clock = clock
+
+	// each update comes before the quiet period of the previous one has passed
+	for i := 1; i <= 5; i++ {
+		c.AddDebounced("k", i, 100*time.Millisecond)
+		clock.Advance(50 * time.Millisecond)
+	}
+	if c.Contains("k") {
+		t.Fatal("a value was added before the quiet period passed")
+	}
+	clock.Advance(49 * time.Millisecond)
+	if c.Contains("k") {
+		t.Fatal("a value was added before the quiet period passed")
+	}
+	clock.Advance(time.Millisecond)
+	if value, ok := c.Peek("k"); !ok || value != 5 || len(adds) != 1 {
+		t.Fatalf("k = %d, %v after %d adds, want only the last value 5 added once", value, ok, len(adds))
+	}
+}
+
+func TestFlush(t *testing.T) {
+	c, err := NewWithCallbacks(4, nil, nil, func(key string, oldValue, newValue int) {
+		t.Errorf("update of %s from %d to %d after Flush", key, oldValue, newValue)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock()
+	c.clock = clock
+	c.AddDebounced("a", 1, time.Second)
+	c.AddDebounced("a", 2, time.Second)
+	c.AddDebounced("b", 3, time.Second)
+
+	if flushed := c.Flush(); flushed != 2 {
+		t.Fatalf("Flush() = %d, want 2", flushed)
+	}
+	if a, _ := c.Peek("a"); a != 2 || !c.Contains("b") {
+		t.Fatalf("a = %d with b present %v, want 2 and true", a, c.Contains("b"))
+	}
+	// the timers of the flushed values are stopped
+	clock.Advance(2 * time.Second)
+	if flushed := c.Flush(); flushed != 0 {
+		t.Fatalf("second Flush() = %d, want 0", flushed)
+	}
+}
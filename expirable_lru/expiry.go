@@ -0,0 +1,80 @@
+package expirable_lru
+
+import (
+	"container/heap"
+	"lru/internal"
+)
+
+// expiryHeap holds the entries not yet counted as expired, the soonest to expire first.
+// Entry.ExpiryIndex keeps the position of each entry plus one, so it can be moved or removed.
+type expiryHeap[K comparable, V any] []*internal.Entry[K, V]
+
+func (h expiryHeap[K, V]) Len() int { return len(h) }
+
+func (h expiryHeap[K, V]) Less(i, j int) bool { return h[i].ExpiresAt.Before(h[j].ExpiresAt) }
+
+func (h expiryHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].ExpiryIndex = i + 1
+	h[j].ExpiryIndex = j + 1
+}
+
+func (h *expiryHeap[K, V]) Push(x any) {
+	entry := x.(*internal.Entry[K, V])
+	entry.ExpiryIndex = len(*h) + 1
+	*h = append(*h, entry)
+}
+
+func (h *expiryHeap[K, V]) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	entry.ExpiryIndex = 0
+	return entry
+}
+
+// countedExpired is the ExpiryIndex of the entries moved from the heap to the count of expired entries.
+const countedExpired = -1
+
+// trackExpiry starts tracking the expiration time of the entry, or its new expiration time
+// if it is tracked already. Has to be called with lock!
+func (l *LRU[K, V]) trackExpiry(entry *internal.Entry[K, V]) {
+	switch entry.ExpiryIndex {
+	case 0:
+		heap.Push(&l.expiring, entry)
+	case countedExpired:
+		if l.now().After(entry.ExpiresAt) {
+			return
+		}
+		l.expired--
+		heap.Push(&l.expiring, entry)
+	default:
+		heap.Fix(&l.expiring, entry.ExpiryIndex-1)
+	}
+}
+
+// untrackExpiry stops tracking the expiration time of the entry. Has to be called with lock!
+func (l *LRU[K, V]) untrackExpiry(entry *internal.Entry[K, V]) {
+	switch entry.ExpiryIndex {
+	case 0:
+		return
+	case countedExpired:
+		l.expired--
+	default:
+		heap.Remove(&l.expiring, entry.ExpiryIndex-1)
+	}
+	entry.ExpiryIndex = 0
+}
+
+// countExpired moves the entries which expired since the last call from the heap to the count
+// of expired entries, each entry once, and returns the count. Has to be called with lock!
+func (l *LRU[K, V]) countExpired() int {
+	now := l.now()
+	for len(l.expiring) > 0 && now.After(l.expiring[0].ExpiresAt) {
+		entry := heap.Pop(&l.expiring).(*internal.Entry[K, V])
+		entry.ExpiryIndex = countedExpired
+		l.expired++
+	}
+	return l.expired
+}
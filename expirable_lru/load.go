@@ -0,0 +1,62 @@
+package expirable_lru
+
+import (
+	"errors"
+	"time"
+)
+
+// errLoaderPanicked is returned to the callers waiting for a loader which panicked.
+var errLoaderPanicked = errors.New("expirable_lru: loader panicked")
+
+// load is the state of a key being loaded by GetOrLoadWithTTL, shared by the loading caller and the waiters.
+type load[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// GetOrLoadWithTTL returns key's value from the cache and updates the recency of usage of the key.
+// If the key is missing or has expired, loader is called without the lock held, and the value it returns
+// is added expiring after the returned TTL, 0 making it never expire. Concurrent callers for the same key
+// wait for the first one to load the value instead of calling loader. If loader returns an error,
+// nothing is added and the error is returned to all of them.
+func (l *LRU[K, V]) GetOrLoadWithTTL(key K, loader func() (value V, ttl time.Duration, err error)) (V, error) {
+	if value, ok := l.Get(key); ok {
+		return value, nil
+	}
+	l.lock.Lock()
+	// the key may have been loaded since Get
+	if entry, ok := l.entries[key]; ok && !l.now().After(entry.ExpiresAt) {
+		l.unlock()
+		return entry.Value, nil
+	}
+	if ld, ok := l.loads[key]; ok {
+		l.unlock()
+		<-ld.done
+		return ld.value, ld.err
+	}
+	if l.loads == nil {
+		l.loads = make(map[K]*load[V])
+	}
+	ld := &load[V]{done: make(chan struct{}), err: errLoaderPanicked}
+	l.loads[key] = ld
+	l.unlock()
+
+	var ttl time.Duration
+	// release the waiters even if loader panics
+	defer func() {
+		l.lock.Lock()
+		delete(l.loads, key)
+		if ld.err == nil {
+			l.add(key, ld.value, l.now().Add(ttl))
+		}
+		l.unlock()
+		close(ld.done)
+	}()
+	value, ttl, err := loader()
+	if ttl <= 0 {
+		ttl = noEvictionTTL
+	}
+	ld.value, ld.err = value, err
+	return value, err
+}
@@ -1,7 +1,13 @@
 package expirable_lru
 
 import (
+	"errors"
+	"fmt"
+	"iter"
 	"lru/internal"
+	"reflect"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -10,30 +16,263 @@ const (
 	// noEvictionTTL is a very long TTL to prevent eviction
 	noEvictionTTL = time.Hour * 24 * 365 * 100
 
-	// because of uint8 usage for nextBucket, it should not exceed 256
-	// casting it to uint8 explicitly requires type conversions in multiple places
-	numBuckets = 100
+	// defaultNumBuckets is the number of expiry buckets unless it makes the sweep interval out of bounds
+	defaultNumBuckets = 100
+	// because of uint8 usage for nextBucket and Entry.Bucket, it should not exceed 256
+	maxNumBuckets = 256
+
+	// bounds of the sweep interval kept by picking the number of buckets
+	minSweepInterval = time.Millisecond * 10
+	maxSweepInterval = time.Second
+
+	// ttlFuncSweepRound sets how often expired entries are deleted when WithTTLFunc is given without a TTL
+	ttlFuncSweepRound = time.Second
 )
 
+// Number is a constraint for the value types that can be used as counters.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
 // EvictCallback is used to get a callback when a cache entry is evicted
 type EvictCallback[K comparable, V any] func(key K, value V)
 
+// EvictReason tells why an entry left the cache.
+type EvictReason int
+
+const (
+	// Evicted means the entry was the oldest one when the cache was full or got resized.
+	Evicted EvictReason = iota
+	// Expired means the entry outlived its TTL or was expired by Expire.
+	Expired
+	// Removed means the entry was removed by a call like Remove or Purge.
+	Removed
+)
+
+// String returns the name of the reason.
+func (r EvictReason) String() string {
+	switch r {
+	case Evicted:
+		return "Evicted"
+	case Expired:
+		return "Expired"
+	case Removed:
+		return "Removed"
+	}
+	return "EvictReason(" + strconv.Itoa(int(r)) + ")"
+}
+
+// ExportedEntry holds a cache entry with its metadata, as returned by Export.
+type ExportedEntry[K comparable, V any] struct {
+	Key         K
+	Value       V
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	AccessCount uint64
+}
+
+// Option configures optional behavior of the LRU.
+type Option[K comparable, V any] func(l *LRU[K, V])
+
+// WithOnEvict sets the callback invoked when an entry is evicted, expires or is removed.
+func WithOnEvict[K comparable, V any](onEvict EvictCallback[K, V]) Option[K, V] {
+	return func(l *LRU[K, V]) {
+		l.onEvict = onEvict
+	}
+}
+
+// WithTTL sets the time after which added entries expire, 0 turns expiring off.
+func WithTTL[K comparable, V any](ttl time.Duration) Option[K, V] {
+	return func(l *LRU[K, V]) {
+		l.ttl = ttl
+	}
+}
+
+// WithBuckets sets the number of expiry buckets instead of picking it from the TTL,
+// so expired entries are deleted every TTL / n. More buckets delete expired entries sooner,
+// a few at a time, but wake the sweep more often, which matters for short TTLs.
+// Fewer buckets keep expired entries longer, up to the whole TTL with one bucket.
+// New returns an error for 0 buckets, NewLRU picks the number from the TTL.
+func WithBuckets[K comparable, V any](n uint8) Option[K, V] {
+	return func(l *LRU[K, V]) {
+		l.numBuckets = n
+		l.bucketsSet = true
+	}
+}
+
+// withClock makes the cache read the current time from now instead of the system clock.
+// The background sweep still waits in real time, so it is used along with WithoutBackgroundSweep.
+func withClock[K comparable, V any](now func() time.Time) Option[K, V] {
+	return func(l *LRU[K, V]) {
+		l.now = now
+	}
+}
+
+// WithTTLFunc makes the TTL of an added entry depend on its value instead of being the same for all entries.
+// A returned TTL of 0 makes the entry never expire.
+func WithTTLFunc[K comparable, V any](ttlFunc func(value V) time.Duration) Option[K, V] {
+	return func(l *LRU[K, V]) {
+		l.ttlFunc = ttlFunc
+	}
+}
+
+// WithoutBackgroundSweep turns off the goroutine deleting expired entries.
+// Expired entries are still not returned, and when the cache is full,
+// Add evicts an expired entry, if it finds a bucket of them, before the oldest entry.
+func WithoutBackgroundSweep[K comparable, V any]() Option[K, V] {
+	return func(l *LRU[K, V]) {
+		l.noSweep = true
+	}
+}
+
+// WithEvictReasonCallback sets a callback invoked with the reason whenever an entry leaves the cache,
+// in addition to the eviction callback passed to NewLRU.
+func WithEvictReasonCallback[K comparable, V any](onEvictReason func(key K, value V, reason EvictReason)) Option[K, V] {
+	return func(l *LRU[K, V]) {
+		l.onEvictReason = onEvictReason
+	}
+}
+
+// WithExtendAfterAccesses makes Get reset the expiration time of an entry once it has been read
+// the given number of times, so frequently read entries stay while the rest expire as usual.
+func WithExtendAfterAccesses[K comparable, V any](accesses int) Option[K, V] {
+	return func(l *LRU[K, V]) {
+		l.extendAfterAccesses = accesses
+	}
+}
+
+// WithSlidingExpiration makes every Get reset the expiration time of the entry, so entries expire
+// only after not being read for the TTL. It is the same as WithExtendAfterAccesses(1).
+func WithSlidingExpiration[K comparable, V any]() Option[K, V] {
+	return WithExtendAfterAccesses[K, V](1)
+}
+
+// WithGracePeriod makes Get keep returning an expired entry until the grace period after
+// its expiration time has passed, serving slightly stale values. GetWithExpiry returns
+// an expiration time in the past for such values. Other reads treat the entry as expired,
+// and the background sweep deletes it only after the grace period.
+func WithGracePeriod[K comparable, V any](gracePeriod time.Duration) Option[K, V] {
+	return func(l *LRU[K, V]) {
+		l.gracePeriod = gracePeriod
+	}
+}
+
+// WithSoftExpiry makes Peek keep returning expired entries until they are deleted,
+// by the background sweep or to make room, while Get treats them as missing.
+// Callers opt into stale values by calling Peek.
+func WithSoftExpiry[K comparable, V any]() Option[K, V] {
+	return func(l *LRU[K, V]) {
+		l.softExpiry = true
+	}
+}
+
+// WithZeroValueDeletes makes adding the zero value of V remove the key instead of storing the value,
+// like a tombstone. It applies to the writes of whole values, e.g. Replace or Import, and makes legitimate zero values,
+// like 0 or an empty string, impossible to store. Removed keys are reported with the Removed reason.
+func WithZeroValueDeletes[K comparable, V any]() Option[K, V] {
+	return func(l *LRU[K, V]) {
+		l.zeroValueDeletes = true
+	}
+}
+
+// Observer receives the events of the cache, e.g. to feed a metrics system.
+// OnEvict is called with the lock held, like the eviction callback, so it must not call the cache.
+// The other methods are called after the lock is released.
+type Observer[K comparable, V any] interface {
+	// OnHit is called when Get or Peek finds the key.
+	OnHit(key K)
+	// OnMiss is called when Get or Peek doesn't find the key or finds it expired.
+	OnMiss(key K)
+	// OnEvict is called when an entry is evicted, expires or is removed, along with the eviction callback.
+	OnEvict(key K, value V)
+	// OnAdd is called when a value is added or updated.
+	OnAdd(key K)
+}
+
+// observerEvent is an event of the cache reported to the observer after the lock is released.
+type observerEvent uint8
+
+const (
+	hitEvent observerEvent = iota
+	missEvent
+	addEvent
+)
+
+// observed is an event of the key waiting for the lock to be released.
+type observed[K comparable] struct {
+	key   K
+	event observerEvent
+}
+
+// WithObserver reports the events of the cache to observer.
+func WithObserver[K comparable, V any](observer Observer[K, V]) Option[K, V] {
+	return func(l *LRU[K, V]) {
+		l.observer = observer
+	}
+}
+
+// WithCopyOnGet makes Get and Peek return the copy of the value made by copyValue,
+// so callers changing a returned slice or struct with pointers don't change the cached value.
+// Other reads, like Values, return the cached values.
+func WithCopyOnGet[K comparable, V any](copyValue func(value V) V) Option[K, V] {
+	return func(l *LRU[K, V]) {
+		l.copyOnGet = copyValue
+	}
+}
+
 // LRU implements a thread-safe LRU with expirable entries.
 type LRU[K comparable, V any] struct {
 	size      int
 	evictList *internal.LRUList[K, V]
 	entries   map[K]*internal.Entry[K, V]
 	onEvict   EvictCallback[K, V]
+	// called along with onEvict with the reason of the eviction
+	onEvictReason func(key K, value V, reason EvictReason)
+	observer      Observer[K, V]
+	// events for the observer reported after the lock is released
+	observed []observed[K]
 
 	// expirable options
-	lock sync.Mutex
-	ttl  time.Duration
-	done chan struct{}
+	lock    sync.Mutex
+	ttl     time.Duration
+	ttlFunc func(value V) time.Duration
+	noSweep bool
+	done    chan struct{}
+	closed  bool
+	// source of the current time, replaced by tests
+	now func() time.Time
+	// number of reads after which Get resets the expiration time, 0 never does
+	extendAfterAccesses int
+	// time after expiration during which Get still returns the entry
+	gracePeriod time.Duration
+	// whether Peek returns expired entries
+	softExpiry bool
+	// whether adding the zero value removes the key
+	zeroValueDeletes bool
+	// makes the copies of the values returned by Get and Peek, nil returns the cached values
+	copyOnGet func(value V) V
+	// keys being loaded by GetOrLoadWithTTL
+	loads map[K]*load[V]
+	// time the evicted and expired entries spent in the cache, and their number
+	residencyTotal time.Duration
+	residencyCount uint64
 
 	// buckets for expiration
 	buckets []bucket[K, V]
-	// uint8 because it's a number between 0 and numBuckets
+	// number of buckets set by WithBuckets and whether it was called, 0 picks it from the TTL
+	numBuckets uint8
+	bucketsSet bool
+	// uint8 because it's a number between 0 and len(buckets)
 	nextBucket uint8
+	// time nextBucket was last moved on by rotateBuckets, without the background sweep
+	rotatedAt time.Time
+
+	// entries not yet counted as expired, by expiration time, and the number of those counted,
+	// which keeps Len from walking the list
+	expiring expiryHeap[K, V]
+	expired  int
 }
 
 // bucket is a container for holding entries to be expired
@@ -48,36 +287,103 @@ type bucket[K comparable, V any] struct {
 //
 // Providing 0 TTL turns expiring off.
 //
-// Delete expired entries every 1/100th of TTL value. Goroutine which deletes expired entries runs indefinitely.
-func NewLRU[K comparable, V any](size int, onEvict EvictCallback[K, V], ttl time.Duration) *LRU[K, V] {
+// Expired entries are kept in buckets, one of which is cleaned up every TTL / number of buckets.
+// There are 100 buckets, unless that makes the interval shorter than 10ms or longer than 1s,
+// then the number of buckets is picked to keep the interval at that bound, between 1 and 256 buckets.
+// Without a TTL there is a single bucket. WithBuckets sets the number of buckets instead.
+// Goroutine which deletes expired entries runs until Close is called.
+// The TTL still sets how often expired entries are deleted when the options change the TTL of the entries,
+// with WithTTLFunc and 0 TTL they are deleted as if the TTL was 1s.
+func NewLRU[K comparable, V any](size int, onEvict EvictCallback[K, V], ttl time.Duration, opts ...Option[K, V]) *LRU[K, V] {
 	if size < 0 {
 		size = 0
 	}
-	if ttl <= 0 {
-		ttl = noEvictionTTL
+	l := newLRU(size, append([]Option[K, V]{WithOnEvict(onEvict), WithTTL[K, V](ttl)}, opts...))
+	l.start()
+	return l
+}
+
+// New returns a new thread-safe cache with expirable entries configured by the options,
+// e.g. WithOnEvict and WithTTL, which otherwise work as in NewLRU. Unlike NewLRU,
+// it returns an error for a negative size or TTL and for the options which need a TTL,
+// like WithSlidingExpiration, given without WithTTL or WithTTLFunc.
+func New[K comparable, V any](size int, opts ...Option[K, V]) (*LRU[K, V], error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid cache size (%d), must not be negative", size)
+	}
+	l := newLRU(size, opts)
+	if err := l.validate(); err != nil {
+		return nil, err
 	}
+	l.start()
+	return l, nil
+}
 
+// newLRU returns the cache configured by the options, without the TTL made valid and the buckets.
+func newLRU[K comparable, V any](size int, opts []Option[K, V]) *LRU[K, V] {
 	l := &LRU[K, V]{
 		size:      size,
 		evictList: internal.NewList[K, V](),
 		entries:   make(map[K]*internal.Entry[K, V]),
-		onEvict:   onEvict,
-		ttl:       ttl,
+		now:       time.Now,
 		done:      make(chan struct{}),
 	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// validate returns an error if the options of the cache conflict.
+func (l *LRU[K, V]) validate() error {
+	if l.ttl < 0 {
+		return fmt.Errorf("invalid TTL (%v), must not be negative", l.ttl)
+	}
+	if l.bucketsSet && l.numBuckets == 0 {
+		return errors.New("invalid number of buckets (0), must be bigger than zero")
+	}
+	if l.ttl > 0 || l.ttlFunc != nil {
+		return nil
+	}
+	switch {
+	case l.extendAfterAccesses > 0:
+		return errors.New("sliding expiration requires a TTL")
+	case l.gracePeriod > 0:
+		return errors.New("grace period requires a TTL")
+	case l.softExpiry:
+		return errors.New("soft expiry requires a TTL")
+	case l.noSweep:
+		return errors.New("turning off the background sweep requires a TTL")
+	}
+	return nil
+}
+
+// start makes the TTL valid, creates the buckets and starts the goroutine deleting expired entries.
+func (l *LRU[K, V]) start() {
+	switch {
+	case l.ttl > 0:
+	case l.ttlFunc != nil:
+		// the entries expire although there is no TTL, so the sweep still needs one
+		l.ttl = ttlFuncSweepRound
+	default:
+		l.ttl = noEvictionTTL
+	}
 
+	numBuckets := int(l.numBuckets)
+	if numBuckets == 0 {
+		numBuckets = numBucketsFor(l.ttl)
+	}
 	l.buckets = make([]bucket[K, V], numBuckets)
-	for i := 0; i < numBuckets; i++ {
+	for i := range l.buckets {
 		l.buckets[i] = bucket[K, V]{entries: make(map[K]*internal.Entry[K, V])}
 	}
+	l.rotatedAt = l.now()
 
-	// enable deleteExpired() running in a separate goroutine for cache with non-zero TTL.
-	//
-	// Important: done channel is never closed, so deleteExpired() goroutine will never exit.
-	// This functionality is not implemented yet.
-	if l.ttl != noEvictionTTL {
+	// enable deleteExpired() running in a separate goroutine for cache with non-zero TTL,
+	// it exits when done channel is closed by Close().
+	if l.sweeping() {
 		go func() {
-			ticker := time.NewTicker(l.ttl / numBuckets)
+			ticker := time.NewTicker(l.sweepInterval())
 			defer ticker.Stop()
 			for {
 				select {
@@ -89,21 +395,157 @@ func NewLRU[K comparable, V any](size int, onEvict EvictCallback[K, V], ttl time
 			}
 		}()
 	}
-
-	return l
 }
 
 // Add adds an entry to the cache, returns true if an eviction occurred and
 // updates the recency of usage of the key.
 func (l *LRU[K, V]) Add(key K, value V) (evicted bool) {
 	l.lock.Lock()
-	defer l.lock.Unlock()
+	defer l.unlock()
+	return l.add(key, value, l.now().Add(l.ttlOf(value)))
+}
 
-	expiresAt := time.Now().Add(l.ttl)
+// AddWithTTL adds an entry expiring after the given ttl instead of the cache TTL,
+// returns true if an eviction occurred and updates the recency of usage of the key.
+// Providing 0 TTL makes the entry never expire.
+//
+// The entry goes to the same expiry bucket as the others, and as the sweep deletes only expired entries
+// from a bucket, an entry with a TTL longer than the cache TTL stays there until a later sweep.
+func (l *LRU[K, V]) AddWithTTL(key K, value V, ttl time.Duration) (evicted bool) {
+	l.lock.Lock()
+	defer l.unlock()
+	if ttl <= 0 {
+		ttl = noEvictionTTL
+	}
+	return l.add(key, value, l.now().Add(ttl))
+}
+
+// IncrementWithTTL adds delta to the counter stored under the key and updates the recency of usage of the key.
+// If the key is missing or expired, a new counter starting at delta is added, expiring after ttl.
+// The expiration time of an existing counter is left untouched, which makes it a fixed window.
+// Providing 0 TTL makes a new counter never expire.
+func IncrementWithTTL[K comparable, V Number](l *LRU[K, V], key K, delta V, ttl time.Duration) (newValue V) {
+	l.lock.Lock()
+	defer l.unlock()
+	now := l.now()
+	if entry, ok := l.entries[key]; ok && !now.After(entry.ExpiresAt) {
+		l.evictList.MoveToFront(entry)
+		entry.LastAccess = now
+		entry.Value += delta
+		return entry.Value
+	}
+	if ttl <= 0 {
+		ttl = noEvictionTTL
+	}
+	l.add(key, delta, now.Add(ttl))
+	return delta
+}
+
+// RefreshMany resets the expiration time of the given keys to expire after ttl
+// without updating the recency of usage, returns the number of refreshed keys.
+// Missing and expired keys are skipped. Providing 0 TTL makes the keys never expire.
+func (l *LRU[K, V]) RefreshMany(keys []K, ttl time.Duration) (refreshed int) {
+	l.lock.Lock()
+	defer l.unlock()
+	if ttl <= 0 {
+		ttl = noEvictionTTL
+	}
+	now := l.now()
+	for _, key := range keys {
+		entry, ok := l.entries[key]
+		if !ok || now.After(entry.ExpiresAt) {
+			continue
+		}
+		l.removeFromBucket(entry)
+		entry.ExpiresAt = now.Add(ttl)
+		l.addToBucket(entry)
+		refreshed++
+	}
+	return refreshed
+}
+
+// TouchTTL resets the expiration time of the key to expire after the TTL of a new entry
+// and updates the recency of usage of the key. ok specifies if the key was found or not,
+// an expired key is not touched.
+func (l *LRU[K, V]) TouchTTL(key K) (ok bool) {
+	l.lock.Lock()
+	defer l.unlock()
+	if entry, ok := l.entries[key]; ok {
+		return l.touch(entry, l.ttlOf(entry.Value))
+	}
+	return false
+}
+
+// TouchTTLWith resets the expiration time of the key to expire after ttl and updates the recency
+// of usage of the key. Providing 0 TTL makes the key never expire. ok specifies if the key was found
+// or not, an expired key is not touched.
+func (l *LRU[K, V]) TouchTTLWith(key K, ttl time.Duration) (ok bool) {
+	l.lock.Lock()
+	defer l.unlock()
+	if ttl <= 0 {
+		ttl = noEvictionTTL
+	}
+	if entry, ok := l.entries[key]; ok {
+		return l.touch(entry, ttl)
+	}
+	return false
+}
+
+// touch makes the entry expire after ttl and the newest one, unless it has expired. Has to be called with lock!
+func (l *LRU[K, V]) touch(entry *internal.Entry[K, V], ttl time.Duration) (ok bool) {
+	now := l.now()
+	if now.After(entry.ExpiresAt) {
+		return false
+	}
+	l.evictList.MoveToFront(entry)
+	entry.LastAccess = now
+	l.removeFromBucket(entry)
+	entry.ExpiresAt = now.Add(ttl)
+	l.addToBucket(entry)
+	return true
+}
+
+// ExtendIf resets the expiration time of the entries matching the predicate to expire after ttl
+// without updating the recency of usage, returns the number of extended entries.
+// Expired entries are skipped. Providing 0 TTL makes the entries never expire.
+// match is called with the lock held, so it must not call the cache.
+func (l *LRU[K, V]) ExtendIf(match func(key K, value V) bool, ttl time.Duration) (extended int) {
+	l.lock.Lock()
+	defer l.unlock()
+	if ttl <= 0 {
+		ttl = noEvictionTTL
+	}
+	now := l.now()
+	for _, entry := range l.entries {
+		if now.After(entry.ExpiresAt) || !match(entry.Key, entry.Value) {
+			continue
+		}
+		l.removeFromBucket(entry)
+		entry.ExpiresAt = now.Add(ttl)
+		l.addToBucket(entry)
+		extended++
+	}
+	return extended
+}
+
+// add adds an entry expiring at expiresAt to the cache, returns true if an eviction occurred.
+// Has to be called with lock!
+func (l *LRU[K, V]) add(key K, value V, expiresAt time.Time) (evicted bool) {
+	// a zero value stands for deleting the key if configured so
+	if l.zeroValueDeletes && reflect.ValueOf(&value).Elem().IsZero() {
+		if entry, ok := l.entries[key]; ok {
+			l.removeEntry(entry, Removed)
+		}
+		return false
+	}
+	if l.observer != nil {
+		l.observed = append(l.observed, observed[K]{key: key, event: addEvent})
+	}
 
 	// check for existing entry
 	if entry, ok := l.entries[key]; ok {
 		l.evictList.MoveToFront(entry)
+		entry.LastAccess = l.now()
 		// remove the entry from its current bucket as expiresAt is updated
 		l.removeFromBucket(entry)
 		entry.Value = value
@@ -114,6 +556,8 @@ func (l *LRU[K, V]) Add(key K, value V) (evicted bool) {
 
 	// add new entry
 	entry := l.evictList.PushToFrontExpirable(key, value, expiresAt)
+	entry.CreatedAt = l.now()
+	entry.LastAccess = entry.CreatedAt
 	l.entries[key] = entry
 	// adds the entry to the appropriate bucket and sets entry.Bucket
 	l.addToBucket(entry)
@@ -128,49 +572,153 @@ func (l *LRU[K, V]) Add(key K, value V) (evicted bool) {
 // Get returns key's value from the cache and updates the recency of usage of the key.
 // ok specifies if the key was found or not.
 func (l *LRU[K, V]) Get(key K) (value V, ok bool) {
+	value, _, ok = l.GetWithExpiry(key)
+	return value, ok
+}
+
+// GetWithExpiry works like Get and also returns the time the entry expires at.
+// The zero time is returned if the key is missing or has expired.
+func (l *LRU[K, V]) GetWithExpiry(key K) (value V, expiresAt time.Time, ok bool) {
 	l.lock.Lock()
-	defer l.lock.Unlock()
+	defer l.unlock()
+	value, expiresAt, ok = l.getWithExpiry(key)
+	l.observeLookup(key, ok)
+	if ok && l.copyOnGet != nil {
+		value = l.copyOnGet(value)
+	}
+	return value, expiresAt, ok
+}
+
+// getWithExpiry looks the key up like GetWithExpiry. Has to be called with lock!
+func (l *LRU[K, V]) getWithExpiry(key K) (value V, expiresAt time.Time, ok bool) {
 	if entry, ok := l.entries[key]; ok {
-		// check if entry has expired
-		if time.Now().After(entry.ExpiresAt) {
-			return value, false
+		now := l.now()
+		// check if entry has expired, including the grace period, and delete it right away
+		// unless expired entries are still readable through Peek
+		if now.After(entry.ExpiresAt.Add(l.gracePeriod)) {
+			if !l.softExpiry {
+				l.removeEntry(entry, Expired)
+			}
+			return value, expiresAt, false
 		}
 		l.evictList.MoveToFront(entry)
-		return entry.Value, true
+		entry.Accessed(now)
+		if l.extendAfterAccesses > 0 && entry.AccessCount >= uint64(l.extendAfterAccesses) {
+			l.removeFromBucket(entry)
+			entry.ExpiresAt = now.Add(l.ttlOf(entry.Value))
+			l.addToBucket(entry)
+		}
+		return entry.Value, entry.ExpiresAt, true
 	}
-	return value, ok
+	return value, expiresAt, ok
+}
+
+// Replace replaces key's value, resets its expiration time and updates the recency of usage of the key.
+// ok specifies if the key was found or not, a missing or expired key is not added.
+func (l *LRU[K, V]) Replace(key K, value V) (ok bool) {
+	l.lock.Lock()
+	defer l.unlock()
+	now := l.now()
+	if entry, ok := l.entries[key]; ok && !now.After(entry.ExpiresAt) {
+		l.add(key, value, now.Add(l.ttlOf(value)))
+		return true
+	}
+	return false
 }
 
 // Contains checks if a key exists in the cache without updating the recency of usage.
 func (l *LRU[K, V]) Contains(key K) (ok bool) {
 	l.lock.Lock()
-	defer l.lock.Unlock()
+	defer l.unlock()
 	_, ok = l.entries[key]
 	return ok
 }
 
+// ContainsMany checks which of the keys exist in the cache without updating the recency of usage,
+// returns whether each key was found in the same order as keys. Expired keys are not found.
+func (l *LRU[K, V]) ContainsMany(keys []K) []bool {
+	l.lock.Lock()
+	defer l.unlock()
+	found := make([]bool, len(keys))
+	now := l.now()
+	for i, key := range keys {
+		entry, ok := l.entries[key]
+		found[i] = ok && !now.After(entry.ExpiresAt)
+	}
+	return found
+}
+
+// MissingKeys returns the keys which don't exist in the cache or have expired,
+// without updating the recency of usage.
+func (l *LRU[K, V]) MissingKeys(keys []K) []K {
+	l.lock.Lock()
+	defer l.unlock()
+	var missing []K
+	now := l.now()
+	for _, key := range keys {
+		if entry, ok := l.entries[key]; !ok || now.After(entry.ExpiresAt) {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
 // Peek returns key's value without updating the recency of usage of the key.
 // ok specifies if the key was found or not.
 func (l *LRU[K, V]) Peek(key K) (value V, ok bool) {
+	value, _, ok = l.PeekWithExpiry(key)
+	return value, ok
+}
+
+// PeekWithExpiry works like Peek and also returns the time the entry expires at.
+// The zero time is returned if the key is missing or has expired, unless WithSoftExpiry is used.
+func (l *LRU[K, V]) PeekWithExpiry(key K) (value V, expiresAt time.Time, ok bool) {
 	l.lock.Lock()
-	defer l.lock.Unlock()
+	defer l.unlock()
+	value, expiresAt, ok = l.peekWithExpiry(key)
+	l.observeLookup(key, ok)
+	if ok && l.copyOnGet != nil {
+		value = l.copyOnGet(value)
+	}
+	return value, expiresAt, ok
+}
+
+// peekWithExpiry looks the key up like PeekWithExpiry. Has to be called with lock!
+func (l *LRU[K, V]) peekWithExpiry(key K) (value V, expiresAt time.Time, ok bool) {
 	if entry, ok := l.entries[key]; ok {
-		// check if entry has expired
-		if time.Now().After(entry.ExpiresAt) {
-			return value, false
+		// check if entry has expired, unless expired entries are still readable,
+		// and delete it right away unless Get still returns it during the grace period
+		if now := l.now(); !l.softExpiry && now.After(entry.ExpiresAt) {
+			if now.After(entry.ExpiresAt.Add(l.gracePeriod)) {
+				l.removeEntry(entry, Expired)
+			}
+			return value, expiresAt, false
 		}
-		return entry.Value, true
+		return entry.Value, entry.ExpiresAt, true
 	}
-	return value, ok
+	return value, expiresAt, ok
 }
 
 // Remove removes an entry from the cache with the key specified.
 // ok specifies if the key was found or not.
 func (l *LRU[K, V]) Remove(key K) (ok bool) {
 	l.lock.Lock()
-	defer l.lock.Unlock()
+	defer l.unlock()
+	if entry, ok := l.entries[key]; ok {
+		l.removeEntry(entry, Removed)
+		return true
+	}
+	return false
+}
+
+// Expire removes an entry from the cache with the key specified as if it expired,
+// the eviction reason callback gets Expired instead of Removed.
+// ok specifies if the key was found or not.
+func (l *LRU[K, V]) Expire(key K) (ok bool) {
+	l.lock.Lock()
+	defer l.unlock()
 	if entry, ok := l.entries[key]; ok {
-		l.removeEntry(entry)
+		l.removeEntry(entry, Expired)
 		return true
 	}
 	return false
@@ -179,31 +727,86 @@ func (l *LRU[K, V]) Remove(key K) (ok bool) {
 // RemoveOldest removes the oldest entry from the cache.
 func (l *LRU[K, V]) RemoveOldest() (key K, value V, ok bool) {
 	l.lock.Lock()
-	defer l.lock.Unlock()
+	defer l.unlock()
 	if entry := l.evictList.Back(); entry != nil {
-		l.removeEntry(entry)
+		l.removeEntry(entry, Removed)
 		return entry.Key, entry.Value, true
 	}
 	return key, value, false
 }
 
+// RemoveOlderThan removes the entries added before cutoff regardless of their expiration time,
+// returns the number of removed entries. Overwriting a value doesn't change the time the entry was added.
+func (l *LRU[K, V]) RemoveOlderThan(cutoff time.Time) (removed int) {
+	l.lock.Lock()
+	defer l.unlock()
+	for entry := l.evictList.Back(); entry != nil; {
+		prev := entry.PrevEntry()
+		if entry.CreatedAt.Before(cutoff) {
+			l.removeEntry(entry, Removed)
+			removed++
+		}
+		entry = prev
+	}
+	return removed
+}
+
+// DeleteExpired removes all the expired entries at once instead of waiting for the background sweep,
+// returns the number of removed entries. Entries within the grace period are kept.
+// It is safe to call while the background sweep runs.
+func (l *LRU[K, V]) DeleteExpired() (removed int) {
+	l.lock.Lock()
+	defer l.unlock()
+	now := l.now()
+	for entry := l.evictList.Back(); entry != nil; {
+		prev := entry.PrevEntry()
+		if now.After(entry.ExpiresAt.Add(l.gracePeriod)) {
+			l.removeEntry(entry, Expired)
+			removed++
+		}
+		entry = prev
+	}
+	return removed
+}
+
 // GetOldest returns the oldest entry from the cache.
 func (l *LRU[K, V]) GetOldest() (key K, value V, ok bool) {
 	l.lock.Lock()
-	defer l.lock.Unlock()
+	defer l.unlock()
 	if entry := l.evictList.Back(); entry != nil {
 		return entry.Key, entry.Value, true
 	}
 	return key, value, false
 }
 
+// LongestLived returns the entry which expires last, scanning all the entries.
+// Expired entries are skipped, ok is false if there are no other entries.
+func (l *LRU[K, V]) LongestLived() (key K, value V, expiresAt time.Time, ok bool) {
+	l.lock.Lock()
+	defer l.unlock()
+	now := l.now()
+	var longest *internal.Entry[K, V]
+	for _, entry := range l.entries {
+		if now.After(entry.ExpiresAt) {
+			continue
+		}
+		if longest == nil || entry.ExpiresAt.After(longest.ExpiresAt) {
+			longest = entry
+		}
+	}
+	if longest == nil {
+		return key, value, expiresAt, false
+	}
+	return longest.Key, longest.Value, longest.ExpiresAt, true
+}
+
 // Keys returns a slice of the keys in the cache, from oldest to newest.
 // Expired entries are filtered out.
 func (l *LRU[K, V]) Keys() []K {
 	l.lock.Lock()
-	defer l.lock.Unlock()
+	defer l.unlock()
 	keys := make([]K, 0, l.evictList.Len())
-	now := time.Now()
+	now := l.now()
 	for entry := l.evictList.Back(); entry != nil; entry = entry.PrevEntry() {
 		if now.After(entry.ExpiresAt) {
 			continue
@@ -213,13 +816,29 @@ func (l *LRU[K, V]) Keys() []K {
 	return keys
 }
 
+// Entries returns a copy of the cache contents without updating the recency of usage.
+// Expired entries are filtered out.
+func (l *LRU[K, V]) Entries() map[K]V {
+	l.lock.Lock()
+	defer l.unlock()
+	entries := make(map[K]V, len(l.entries))
+	now := l.now()
+	for key, entry := range l.entries {
+		if now.After(entry.ExpiresAt) {
+			continue
+		}
+		entries[key] = entry.Value
+	}
+	return entries
+}
+
 // Values returns a slice of the values in the cache, from oldest to newest.
 // Expired entries are filtered out.
 func (l *LRU[K, V]) Values() []V {
 	l.lock.Lock()
-	defer l.lock.Unlock()
+	defer l.unlock()
 	values := make([]V, 0, l.evictList.Len())
-	now := time.Now()
+	now := l.now()
 	for entry := l.evictList.Back(); entry != nil; entry = entry.PrevEntry() {
 		if now.After(entry.ExpiresAt) {
 			continue
@@ -229,33 +848,183 @@ func (l *LRU[K, V]) Values() []V {
 	return values
 }
 
-// Len returns the number of entries in the cache.
+// All returns an iterator over the non-expired entries of the cache from oldest to newest,
+// which doesn't update the recency of usage. The entries are copied under the lock
+// when the iteration starts, so the loop body may call the cache.
+func (l *LRU[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		l.lock.Lock()
+		keys := make([]K, 0, l.evictList.Len())
+		values := make([]V, 0, l.evictList.Len())
+		now := l.now()
+		for entry := l.evictList.Back(); entry != nil; entry = entry.PrevEntry() {
+			if now.After(entry.ExpiresAt) {
+				continue
+			}
+			keys = append(keys, entry.Key)
+			values = append(values, entry.Value)
+		}
+		l.unlock()
+		for i := range keys {
+			if !yield(keys[i], values[i]) {
+				return
+			}
+		}
+	}
+}
+
+// RangeByExpiry calls fn for each non-expired entry, from the soonest to expire to the latest,
+// until fn returns false. The lock is held during the whole iteration, so fn must not call the cache.
+func (l *LRU[K, V]) RangeByExpiry(fn func(key K, value V, expiresAt time.Time) bool) {
+	l.lock.Lock()
+	defer l.unlock()
+	entries := make([]*internal.Entry[K, V], 0, len(l.entries))
+	now := l.now()
+	for _, entry := range l.entries {
+		if now.After(entry.ExpiresAt) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b *internal.Entry[K, V]) int {
+		return a.ExpiresAt.Compare(b.ExpiresAt)
+	})
+	for _, entry := range entries {
+		if !fn(entry.Key, entry.Value, entry.ExpiresAt) {
+			return
+		}
+	}
+}
+
+// ExpiringWithin returns the keys of the non-expired entries expiring within d from now,
+// in no particular order. It scans all the entries, so it takes O(n) time.
+func (l *LRU[K, V]) ExpiringWithin(d time.Duration) []K {
+	l.lock.Lock()
+	defer l.unlock()
+	var keys []K
+	now := l.now()
+	until := now.Add(d)
+	for _, entry := range l.entries {
+		if now.After(entry.ExpiresAt) || entry.ExpiresAt.After(until) {
+			continue
+		}
+		keys = append(keys, entry.Key)
+	}
+	return keys
+}
+
+// Export returns the cache entries with their metadata, from oldest to newest.
+// Expired entries are filtered out.
+func (l *LRU[K, V]) Export() []ExportedEntry[K, V] {
+	l.lock.Lock()
+	defer l.unlock()
+	entries := make([]ExportedEntry[K, V], 0, l.evictList.Len())
+	now := l.now()
+	for entry := l.evictList.Back(); entry != nil; entry = entry.PrevEntry() {
+		if now.After(entry.ExpiresAt) {
+			continue
+		}
+		entries = append(entries, ExportedEntry[K, V]{
+			Key:         entry.Key,
+			Value:       entry.Value,
+			CreatedAt:   entry.CreatedAt,
+			ExpiresAt:   entry.ExpiresAt,
+			AccessCount: entry.AccessCount,
+		})
+	}
+	return entries
+}
+
+// Import adds the entries from oldest to newest keeping their metadata,
+// so the entries returned by Export are restored in the same recency order.
+// Expired entries are skipped, entries with zero ExpiresAt get the TTL of a new entry.
+func (l *LRU[K, V]) Import(entries []ExportedEntry[K, V]) {
+	l.lock.Lock()
+	defer l.unlock()
+	now := l.now()
+	for _, e := range entries {
+		expiresAt := e.ExpiresAt
+		if expiresAt.IsZero() {
+			expiresAt = now.Add(l.ttlOf(e.Value))
+		}
+		if now.After(expiresAt) {
+			continue
+		}
+		l.add(e.Key, e.Value, expiresAt)
+		entry, ok := l.entries[e.Key]
+		if !ok {
+			// a zero value deleted the key
+			continue
+		}
+		entry.CreatedAt = e.CreatedAt
+		entry.AccessCount = e.AccessCount
+	}
+}
+
+// Len returns the number of entries in the cache, without the expired entries which are
+// not yet deleted, so it matches the length of Keys. It counts each entry as expired once,
+// so it doesn't walk the list.
 func (l *LRU[K, V]) Len() int {
 	l.lock.Lock()
-	defer l.lock.Unlock()
+	defer l.unlock()
+	return l.evictList.Len() - l.countExpired()
+}
+
+// PhysicalLen returns the number of entries held in the eviction list,
+// including expired entries which are not yet deleted.
+func (l *LRU[K, V]) PhysicalLen() int {
+	l.lock.Lock()
+	defer l.unlock()
 	return l.evictList.Len()
 }
 
+// DeadEstimate returns the number of expired entries which are not yet deleted.
+// A steadily growing value means the expired entries are deleted too late.
+func (l *LRU[K, V]) DeadEstimate() int {
+	l.lock.Lock()
+	defer l.unlock()
+	return l.countExpired()
+}
+
+// AverageResidency returns the mean time the entries spent in the cache from being added
+// until they were evicted or expired, 0 if none left yet. Removed entries are not counted.
+func (l *LRU[K, V]) AverageResidency() time.Duration {
+	l.lock.Lock()
+	defer l.unlock()
+	if l.residencyCount == 0 {
+		return 0
+	}
+	return l.residencyTotal / time.Duration(l.residencyCount)
+}
+
 // Cap returns the capacity of the cache.
 func (l *LRU[K, V]) Cap() int {
 	return l.size
 }
 
-// Purge clears all the cache entries.
+// Purge clears all the cache entries, invoking the eviction callback from oldest to newest.
 func (l *LRU[K, V]) Purge() {
 	l.lock.Lock()
-	defer l.lock.Unlock()
-	for k, v := range l.entries {
+	defer l.unlock()
+	for entry := l.evictList.Back(); entry != nil; entry = entry.PrevEntry() {
 		if l.onEvict != nil {
-			l.onEvict(k, v.Value)
+			l.onEvict(entry.Key, entry.Value)
 		}
-		delete(l.entries, k)
-	}
-	for _, b := range l.buckets {
-		for _, entry := range b.entries {
-			delete(b.entries, entry.Key)
+		if l.onEvictReason != nil {
+			l.onEvictReason(entry.Key, entry.Value, Removed)
+		}
+		if l.observer != nil {
+			l.observer.OnEvict(entry.Key, entry.Value)
 		}
+		delete(l.entries, entry.Key)
 	}
+	for i := range l.buckets {
+		clear(l.buckets[i].entries)
+		l.buckets[i].newestEntry = time.Time{}
+	}
+	clear(l.expiring)
+	l.expiring = l.expiring[:0]
+	l.expired = 0
 	l.evictList.Init()
 }
 
@@ -263,7 +1032,7 @@ func (l *LRU[K, V]) Purge() {
 // Size of 0 means unlimited.
 func (l *LRU[K, V]) Resize(size int) (evicted int) {
 	l.lock.Lock()
-	defer l.lock.Unlock()
+	defer l.unlock()
 	if size <= 0 {
 		l.size = 0
 		return 0
@@ -279,21 +1048,153 @@ func (l *LRU[K, V]) Resize(size int) (evicted int) {
 	return diff
 }
 
+// ExpirationEnabled reports whether the goroutine deleting expired entries is running.
+// It isn't when TTL is 0, the background sweep is turned off or the cache is closed.
+func (l *LRU[K, V]) ExpirationEnabled() bool {
+	l.lock.Lock()
+	defer l.unlock()
+	return l.sweeping()
+}
+
+// Close stops the goroutine deleting expired entries. The cache stays usable and
+// still doesn't return expired entries. Calling Close more than once does nothing.
+func (l *LRU[K, V]) Close() {
+	l.lock.Lock()
+	defer l.unlock()
+	if l.closed {
+		return
+	}
+	l.closed = true
+	close(l.done)
+}
+
+// MaxExpirationLatency returns the upper bound of how late the background sweep deletes an expired entry,
+// which is the sweep interval of TTL / number of buckets, as expired entries are deleted a bucket at a time.
+// Expired entries are never returned, regardless of when they are deleted.
+func (l *LRU[K, V]) MaxExpirationLatency() time.Duration {
+	return l.sweepInterval()
+}
+
+// NextSweep returns the index of the bucket the expired entries are deleted from next
+// and the expiration time of its newest entry, which the deletion waits for.
+// Zero time means the bucket is empty and is skipped on the next tick.
+func (l *LRU[K, V]) NextSweep() (bucket int, at time.Time) {
+	l.lock.Lock()
+	defer l.unlock()
+	return int(l.nextBucket), l.buckets[l.nextBucket].newestEntry
+}
+
+// BucketOccupancy returns the number of entries in each expiry bucket, by bucket index.
+// Empty buckets are sweep ticks which delete nothing, an uneven spread calls for RebalanceBuckets
+// or a different TTL.
+func (l *LRU[K, V]) BucketOccupancy() []int {
+	l.lock.Lock()
+	defer l.unlock()
+	occupancy := make([]int, len(l.buckets))
+	for i, b := range l.buckets {
+		occupancy[i] = len(b.entries)
+	}
+	return occupancy
+}
+
+// RebalanceBuckets refiles the entries into the expiry buckets by their expiration time,
+// each into the bucket swept first after it expires, so the sweeps spread like the expirations.
+// Entries written at once with different TTLs otherwise share a bucket and wait for the newest of them.
+// It takes O(n) time.
+func (l *LRU[K, V]) RebalanceBuckets() {
+	l.lock.Lock()
+	defer l.unlock()
+	l.rebalanceBuckets()
+}
+
+// ttlOf returns the TTL of a new entry with the given value.
+func (l *LRU[K, V]) ttlOf(value V) time.Duration {
+	if l.ttlFunc == nil {
+		return l.ttl
+	}
+	if ttl := l.ttlFunc(value); ttl > 0 {
+		return ttl
+	}
+	return noEvictionTTL
+}
+
 // removeOldest removes the oldest entry from the cache. Has to be called with lock!
+// Without the background sweep, an expired entry is removed instead if a bucket of them is found.
 func (l *LRU[K, V]) removeOldest() {
+	if l.noSweep {
+		if entry := l.expiredEntry(); entry != nil {
+			l.removeEntry(entry, Expired)
+			return
+		}
+	}
 	if entry := l.evictList.Back(); entry != nil {
-		l.removeEntry(entry)
+		l.removeEntry(entry, Evicted)
+	}
+}
+
+// expiredEntry returns an entry of a bucket in which all entries expired, or nil. It checks the expiration time
+// of the newest entry of each bucket instead of walking the list, so expired entries sharing a bucket
+// with live ones are not found. Used when there is no background sweep. Has to be called with lock!
+func (l *LRU[K, V]) expiredEntry() *internal.Entry[K, V] {
+	now := l.now()
+	for _, b := range l.buckets {
+		if len(b.entries) == 0 || !now.After(b.newestEntry) {
+			continue
+		}
+		for _, entry := range b.entries {
+			return entry
+		}
+	}
+	return nil
+}
+
+// observeLookup records a lookup of the key for the observer. Has to be called with lock!
+func (l *LRU[K, V]) observeLookup(key K, found bool) {
+	if l.observer == nil {
+		return
+	}
+	event := missEvent
+	if found {
+		event = hitEvent
+	}
+	l.observed = append(l.observed, observed[K]{key: key, event: event})
+}
+
+// unlock releases the lock and reports the recorded hits, misses and adds to the observer.
+func (l *LRU[K, V]) unlock() {
+	events := l.observed
+	l.observed = nil
+	l.lock.Unlock()
+	for _, e := range events {
+		switch e.event {
+		case hitEvent:
+			l.observer.OnHit(e.key)
+		case missEvent:
+			l.observer.OnMiss(e.key)
+		case addEvent:
+			l.observer.OnAdd(e.key)
+		}
 	}
 }
 
 // removeEntry is used to remove a given list entry from the cache. Has to be called with lock!
-func (l *LRU[K, V]) removeEntry(entry *internal.Entry[K, V]) {
+func (l *LRU[K, V]) removeEntry(entry *internal.Entry[K, V], reason EvictReason) {
 	l.evictList.Remove(entry)
 	delete(l.entries, entry.Key)
 	l.removeFromBucket(entry)
+	if l.observer != nil {
+		l.observer.OnEvict(entry.Key, entry.Value)
+	}
+	if reason != Removed {
+		l.residencyTotal += l.now().Sub(entry.CreatedAt)
+		l.residencyCount++
+	}
 	if l.onEvict != nil {
 		l.onEvict(entry.Key, entry.Value)
 	}
+	if l.onEvictReason != nil {
+		l.onEvictReason(entry.Key, entry.Value, reason)
+	}
 }
 
 // deleteExpired deletes expired entries from the oldest bucket, waiting for the newest entry
@@ -301,24 +1202,69 @@ func (l *LRU[K, V]) removeEntry(entry *internal.Entry[K, V]) {
 func (l *LRU[K, V]) deleteExpired() {
 	l.lock.Lock()
 	bucketIndex := l.nextBucket
-	timeToExpire := time.Until(l.buckets[bucketIndex].newestEntry)
+	timeToExpire := l.buckets[bucketIndex].newestEntry.Add(l.gracePeriod).Sub(l.now())
+	// entries with a long custom TTL must not hold up the cleanup of the other buckets,
+	// so don't wait longer than the sweep interval, whatever is left is deleted in the next round
+	if interval := l.sweepInterval(); timeToExpire > interval {
+		timeToExpire = interval
+	}
 	// wait for newest entry to expire before cleanup without holding lock
 	if timeToExpire > 0 {
-		l.lock.Unlock()
+		l.unlock()
 		time.Sleep(timeToExpire)
 		l.lock.Lock()
 	}
+	// entries with a custom TTL may not have expired yet, they stay in the bucket until the next round
+	var newestEntry time.Time
+	now := l.now()
 	for _, entry := range l.buckets[bucketIndex].entries {
-		l.removeEntry(entry)
+		if !now.After(entry.ExpiresAt.Add(l.gracePeriod)) {
+			if newestEntry.Before(entry.ExpiresAt) {
+				newestEntry = entry.ExpiresAt
+			}
+			continue
+		}
+		l.removeEntry(entry, Expired)
 	}
-	l.nextBucket = (l.nextBucket + 1) % numBuckets
-	l.lock.Unlock()
+	l.buckets[bucketIndex].newestEntry = newestEntry
+	l.nextBucket = uint8((int(l.nextBucket) + 1) % len(l.buckets))
+	l.unlock()
 }
 
-// addToBucket adds entry to expiry bucket so that it will be cleaned up when the time comes.
-// Has to be called with a lock!
+// numBucketsFor returns the number of expiry buckets keeping the sweep interval for the TTL within bounds.
+func numBucketsFor(ttl time.Duration) int {
+	// nothing is swept without a TTL
+	if ttl == noEvictionTTL {
+		return 1
+	}
+	n := defaultNumBuckets
+	switch {
+	case ttl/defaultNumBuckets < minSweepInterval:
+		n = int(ttl / minSweepInterval)
+	case ttl/defaultNumBuckets > maxSweepInterval:
+		n = int(min(ttl/maxSweepInterval, maxNumBuckets))
+	}
+	return max(n, 1)
+}
+
+// sweeping reports whether expired entries are deleted in the background.
+func (l *LRU[K, V]) sweeping() bool {
+	return l.ttl != noEvictionTTL && !l.noSweep && !l.closed
+}
+
+// sweepInterval returns how often expired entries are deleted from the next bucket.
+func (l *LRU[K, V]) sweepInterval() time.Duration {
+	return l.ttl / time.Duration(len(l.buckets))
+}
+
+// addToBucket adds entry to expiry bucket so that it will be cleaned up when the time comes,
+// and tracks its expiration time for Len. Has to be called with a lock!
 func (l *LRU[K, V]) addToBucket(entry *internal.Entry[K, V]) {
-	bucketIndex := l.nextBucket % numBuckets
+	l.trackExpiry(entry)
+	if l.noSweep {
+		l.rotateBuckets()
+	}
+	bucketIndex := l.nextBucket
 	entry.Bucket = bucketIndex
 	l.buckets[bucketIndex].entries[entry.Key] = entry
 	if l.buckets[bucketIndex].newestEntry.Before(entry.ExpiresAt) {
@@ -326,8 +1272,73 @@ func (l *LRU[K, V]) addToBucket(entry *internal.Entry[K, V]) {
 	}
 }
 
-// removeFromBucket removes the entry from its corresponding bucket.
+// RepairBuckets drops the bucket entries which are no longer in the cache or belong to another bucket,
+// and puts back the cache entries missing from their bucket, returns the number of dropped entries.
+// It is a safety net against inconsistencies, which would make expired entries deleted twice or never.
+func (l *LRU[K, V]) RepairBuckets() (orphansRemoved int) {
+	l.lock.Lock()
+	defer l.unlock()
+	for i := range l.buckets {
+		for key, entry := range l.buckets[i].entries {
+			if l.entries[key] != entry || int(entry.Bucket) != i {
+				delete(l.buckets[i].entries, key)
+				orphansRemoved++
+			}
+		}
+	}
+	for key, entry := range l.entries {
+		if l.buckets[entry.Bucket].entries[key] != entry {
+			l.addToBucket(entry)
+		}
+	}
+	return orphansRemoved
+}
+
+// rebalanceBuckets refiles all entries into the buckets by their expiration time, counting
+// the sweep intervals from the bucket swept next. Has to be called with a lock!
+func (l *LRU[K, V]) rebalanceBuckets() {
+	if l.noSweep {
+		l.rotateBuckets()
+	}
+	for i := range l.buckets {
+		l.buckets[i] = bucket[K, V]{entries: make(map[K]*internal.Entry[K, V])}
+	}
+	now, interval, n := l.now(), l.sweepInterval(), len(l.buckets)
+	for _, entry := range l.entries {
+		// entries expiring after a full round of the buckets wait in them for the next rounds
+		offset := 0
+		if wait := entry.ExpiresAt.Add(l.gracePeriod).Sub(now); wait > 0 {
+			offset = int(wait / interval % time.Duration(n))
+		}
+		bucketIndex := uint8((int(l.nextBucket) + offset) % n)
+		entry.Bucket = bucketIndex
+		l.buckets[bucketIndex].entries[entry.Key] = entry
+		if l.buckets[bucketIndex].newestEntry.Before(entry.ExpiresAt) {
+			l.buckets[bucketIndex].newestEntry = entry.ExpiresAt
+		}
+	}
+}
+
+// removeFromBucket removes the entry from its corresponding bucket and stops tracking its expiration time.
 // Has to be called with a lock!
 func (l *LRU[K, V]) removeFromBucket(entry *internal.Entry[K, V]) {
-	delete(l.buckets[entry.Bucket].entries, entry.Key)
+	l.untrackExpiry(entry)
+	b := &l.buckets[entry.Bucket]
+	delete(b.entries, entry.Key)
+	if len(b.entries) == 0 {
+		b.newestEntry = time.Time{}
+	}
+}
+
+// rotateBuckets moves the bucket taking new entries on by the sweep intervals passed, like the sweep does,
+// so without the background sweep entries expiring at different times still get different buckets.
+// Has to be called with a lock!
+func (l *LRU[K, V]) rotateBuckets() {
+	interval := l.sweepInterval()
+	passed := l.now().Sub(l.rotatedAt) / interval
+	if passed <= 0 {
+		return
+	}
+	l.nextBucket = uint8((int(l.nextBucket) + int(passed%time.Duration(len(l.buckets)))) % len(l.buckets))
+	l.rotatedAt = l.rotatedAt.Add(passed * interval)
 }
@@ -0,0 +1,1521 @@
+package expirable_lru
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock moved on by tests.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// newClockLRU returns a cache reading the time from clock, without the background sweep.
+func newClockLRU[K comparable, V any](t *testing.T, size int, ttl time.Duration, clock *fakeClock, opts ...Option[K, V]) *LRU[K, V] {
+	t.Helper()
+	opts = append([]Option[K, V]{withClock[K, V](clock.Now), WithoutBackgroundSweep[K, V]()}, opts...)
+	l := NewLRU[K, V](size, nil, ttl, opts...)
+	t.Cleanup(l.Close)
+	return l
+}
+
+func TestIncrementWithTTL(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, int](t, 0, time.Hour, clock)
+
+	if got := IncrementWithTTL(l, "k", 1, time.Minute); got != 1 {
+		t.Fatalf("first increment = %d, want 1", got)
+	}
+	clock.Advance(30 * time.Second)
+	if got := IncrementWithTTL(l, "k", 2, time.Minute); got != 3 {
+		t.Fatalf("increment within the window = %d, want 3", got)
+	}
+	// the window is fixed, accumulating doesn't extend it
+	clock.Advance(30 * time.Second)
+	if got := IncrementWithTTL(l, "k", 1, time.Minute); got != 4 {
+		t.Fatalf("increment at the end of the window = %d, want 4", got)
+	}
+	clock.Advance(time.Nanosecond)
+	if got := IncrementWithTTL(l, "k", 5, time.Minute); got != 5 {
+		t.Fatalf("increment after rollover = %d, want 5", got)
+	}
+	if _, expiresAt, ok := l.GetWithExpiry("k"); !ok || !expiresAt.Equal(clock.Now().Add(time.Minute)) {
+		t.Fatalf("new window expires at %v (found %v), want %v", expiresAt, ok, clock.Now().Add(time.Minute))
+	}
+}
+
+func TestIncrementWithTTLNoExpiry(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, float64](t, 0, time.Hour, clock)
+
+	IncrementWithTTL(l, "k", 0.5, 0)
+	clock.Advance(24 * 365 * time.Hour)
+	if got := IncrementWithTTL(l, "k", 0.5, 0); got != 1 {
+		t.Fatalf("increment of a counter without TTL = %v, want 1", got)
+	}
+}
+
+func TestPurgeOrder(t *testing.T) {
+	var evicted []int
+	l := NewLRU[int, int](5, func(key, value int) {
+		evicted = append(evicted, key)
+	}, time.Hour)
+	t.Cleanup(l.Close)
+	for _, key := range []int{3, 1, 4, 5, 2} {
+		l.Add(key, key)
+	}
+	l.Get(4)
+	keys := l.Keys()
+
+	l.Purge()
+	if !slices.Equal(evicted, keys) {
+		t.Fatalf("evicted %v, want the order of Keys %v", evicted, keys)
+	}
+}
+
+func TestDeadEstimate(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[int, int](t, 0, time.Minute, clock)
+	for i := 0; i < 5; i++ {
+		l.Add(i, i)
+	}
+	if dead := l.DeadEstimate(); dead != 0 {
+		t.Fatalf("DeadEstimate before expiration = %d, want 0", dead)
+	}
+
+	clock.Advance(2 * time.Minute)
+	l.AddWithTTL(5, 5, time.Hour)
+	if got := l.PhysicalLen(); got != 6 {
+		t.Fatalf("PhysicalLen = %d, want 6", got)
+	}
+	if dead := l.DeadEstimate(); dead != 5 {
+		t.Fatalf("DeadEstimate after expiration without a sweep = %d, want 5", dead)
+	}
+}
+
+func TestRefreshMany(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[int, int](t, 0, time.Minute, clock)
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+	clock.Advance(30 * time.Second)
+	if refreshed := l.RefreshMany([]int{1, 3, 7}, time.Minute); refreshed != 2 {
+		t.Fatalf("RefreshMany = %d, want 2 as key 7 is missing", refreshed)
+	}
+
+	clock.Advance(45 * time.Second)
+	if removed := l.DeleteExpired(); removed != 2 {
+		t.Fatalf("sweep removed %d entries, want 2", removed)
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []int{1, 3}) {
+		t.Fatalf("keys after sweep = %v, want [1 3]", keys)
+	}
+
+	// expired keys are not brought back
+	clock.Advance(time.Hour)
+	if refreshed := l.RefreshMany([]int{1, 3}, time.Minute); refreshed != 0 {
+		t.Fatalf("RefreshMany of expired keys = %d, want 0", refreshed)
+	}
+}
+
+// variance returns the variance of the bucket occupancy.
+func variance(occupancy []int) float64 {
+	var sum float64
+	for _, n := range occupancy {
+		sum += float64(n)
+	}
+	mean := sum / float64(len(occupancy))
+	var v float64
+	for _, n := range occupancy {
+		v += (float64(n) - mean) * (float64(n) - mean)
+	}
+	return v / float64(len(occupancy))
+}
+
+func TestRebalanceBuckets(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[int, int](t, 0, time.Minute, clock)
+	interval := l.sweepInterval()
+	// entries added at once all land in the current bucket, whatever their TTL
+	for i := 0; i < 100; i++ {
+		l.AddWithTTL(i, i, time.Duration(i)*interval+interval/2)
+	}
+	before := l.BucketOccupancy()
+	if variance(before) == 0 {
+		t.Fatalf("occupancy %v is already even", before)
+	}
+
+	l.RebalanceBuckets()
+	after := l.BucketOccupancy()
+	total := 0
+	for _, n := range after {
+		total += n
+	}
+	if total != 100 {
+		t.Fatalf("occupancy %v holds %d entries after rebalancing, want 100", after, total)
+	}
+	// the expirations are one interval apart, so are the buckets
+	if variance(after) >= variance(before) || slices.Max(after) != 1 {
+		t.Fatalf("occupancy %v (variance %v) is not even, was %v (variance %v)", after, variance(after), before, variance(before))
+	}
+
+	// each sweep deletes the entry expired since the previous one
+	for i := 0; i < 100; i++ {
+		clock.Advance(interval)
+		l.deleteExpired()
+		if l.PhysicalLen() != 99-i {
+			t.Fatalf("PhysicalLen after sweep %d = %d, want %d", i, l.PhysicalLen(), 99-i)
+		}
+	}
+}
+
+func TestRebalanceBucketsDueEntries(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[int, int](t, 0, time.Minute, clock)
+	l.Add(1, 1)
+	clock.Advance(30 * time.Second)
+	l.AddWithTTL(2, 2, time.Second)
+	clock.Advance(2 * time.Second)
+
+	// the expired entry goes to the bucket swept next, not the one of 1 half a round later
+	l.RebalanceBuckets()
+	bucket, at := l.NextSweep()
+	if occupancy := l.BucketOccupancy(); occupancy[bucket] != 1 || !at.Equal(clock.Now().Add(-time.Second)) {
+		t.Fatalf("next bucket %d holds %d entries with the newest expiring at %v, want the expired entry",
+			bucket, occupancy[bucket], at)
+	}
+	l.deleteExpired()
+	if l.Contains(2) || l.PhysicalLen() != 1 {
+		t.Fatalf("PhysicalLen after the next sweep = %d, want 1", l.PhysicalLen())
+	}
+}
+
+func TestRemoveOlderThan(t *testing.T) {
+	clock := newFakeClock()
+	var removed []int
+	l := newClockLRU[int, int](t, 0, time.Hour, clock, WithEvictReasonCallback(func(key, value int, reason EvictReason) {
+		if reason != Removed {
+			t.Errorf("key %d removed with reason %v, want Removed", key, reason)
+		}
+		removed = append(removed, key)
+	}))
+	for i := 0; i < 6; i++ {
+		l.Add(i, i)
+		clock.Advance(time.Minute)
+	}
+	cutoff := clock.Now().Add(-3 * time.Minute)
+	// updating an entry doesn't change its creation time
+	l.Add(0, 100)
+
+	if n := l.RemoveOlderThan(cutoff); n != 3 {
+		t.Fatalf("RemoveOlderThan = %d, want 3", n)
+	}
+	slices.Sort(removed)
+	if !slices.Equal(removed, []int{0, 1, 2}) {
+		t.Fatalf("callback got %v, want [0 1 2]", removed)
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []int{3, 4, 5}) {
+		t.Fatalf("keys = %v, want [3 4 5]", keys)
+	}
+	if n := l.RemoveOlderThan(cutoff); n != 0 {
+		t.Fatalf("second RemoveOlderThan = %d, want 0", n)
+	}
+}
+
+func TestNextSweep(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[int, int](t, 0, time.Minute, clock)
+	if bucket, at := l.NextSweep(); bucket != int(l.nextBucket) || !at.IsZero() {
+		t.Fatalf("NextSweep of an empty cache = %d, %v, want %d, zero time", bucket, at, l.nextBucket)
+	}
+
+	start := clock.Now()
+	l.Add(1, 1)
+	bucket, at := l.NextSweep()
+	if bucket != int(l.nextBucket) || !at.Equal(l.buckets[l.nextBucket].newestEntry) {
+		t.Fatalf("NextSweep = %d, %v, want %d, %v", bucket, at, l.nextBucket, l.buckets[l.nextBucket].newestEntry)
+	}
+	if !at.Equal(start.Add(time.Minute)) {
+		t.Fatalf("NextSweep time = %v, want the expiration of the entry %v", at, start.Add(time.Minute))
+	}
+
+	// a later entry with a longer TTL in the same bucket moves the time on
+	l.AddWithTTL(2, 2, 2*time.Minute)
+	if _, at := l.NextSweep(); !at.Equal(start.Add(2 * time.Minute)) {
+		t.Fatalf("NextSweep time = %v, want the expiration of the newest entry %v", at, start.Add(2*time.Minute))
+	}
+
+	// an entry added a sweep interval later goes to another bucket
+	clock.Advance(l.sweepInterval())
+	l.Add(3, 3)
+	if bucket, at := l.NextSweep(); bucket != int(l.nextBucket) || !at.Equal(l.buckets[l.nextBucket].newestEntry) {
+		t.Fatalf("NextSweep = %d, %v, want %d, %v", bucket, at, l.nextBucket, l.buckets[l.nextBucket].newestEntry)
+	}
+	if l.buckets[l.nextBucket].entries[3] == nil {
+		t.Fatal("NextSweep does not report the bucket taking new entries")
+	}
+
+	// Purge empties the buckets
+	l.Purge()
+	if bucket, at := l.NextSweep(); !at.IsZero() || l.BucketOccupancy()[bucket] != 0 {
+		t.Fatalf("NextSweep after Purge = %d, %v, want zero time", bucket, at)
+	}
+	for i, b := range l.buckets {
+		if !b.newestEntry.IsZero() {
+			t.Fatalf("bucket %d keeps the newest expiration %v after Purge", i, b.newestEntry)
+		}
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	clock := newFakeClock()
+	src := newClockLRU[string, int](t, 0, time.Hour, clock)
+	src.Add("a", 1)
+	clock.Advance(time.Minute)
+	src.AddWithTTL("b", 2, 10*time.Minute)
+	src.Add("c", 3)
+	src.Get("a")
+	src.Get("c")
+	src.Get("c")
+	exported := src.Export()
+	if len(exported) != 3 || exported[0].ExpiresAt.IsZero() || exported[0].CreatedAt.IsZero() {
+		t.Fatalf("exported %+v, want 3 entries with their times", exported)
+	}
+
+	clock.Advance(time.Minute)
+	dst := newClockLRU[string, int](t, 0, time.Hour, clock)
+	dst.Import(exported)
+	if !slices.Equal(dst.Export(), exported) {
+		t.Fatalf("round trip changed the entries:\n got %+v\nwant %+v", dst.Export(), exported)
+	}
+	if keys := dst.Keys(); !slices.Equal(keys, []string{"b", "a", "c"}) {
+		t.Fatalf("keys = %v, want the recency order [b a c]", keys)
+	}
+
+	// expired entries are not imported
+	clock.Advance(10 * time.Minute)
+	other := newClockLRU[string, int](t, 0, time.Hour, clock)
+	other.Import(exported)
+	if keys := other.Keys(); !slices.Equal(keys, []string{"a", "c"}) {
+		t.Fatalf("keys = %v, want [a c] as b expired", keys)
+	}
+}
+
+func TestTTLFunc(t *testing.T) {
+	clock := newFakeClock()
+	// the value is the TTL in minutes, 0 never expires
+	ttlFunc := func(value int) time.Duration { return time.Duration(value) * time.Minute }
+	l := newClockLRU[string, int](t, 0, time.Hour, clock, WithTTLFunc[string, int](ttlFunc))
+	l.Add("short", 1)
+	l.Add("medium", 5)
+	l.Add("long", 30)
+	l.Add("forever", 0)
+	for key, want := range map[string]time.Duration{"short": time.Minute, "medium": 5 * time.Minute, "long": 30 * time.Minute} {
+		if _, expiresAt, _ := l.GetWithExpiry(key); !expiresAt.Equal(clock.Now().Add(want)) {
+			t.Fatalf("%s expires at %v, want %v", key, expiresAt, clock.Now().Add(want))
+		}
+	}
+
+	clock.Advance(2 * time.Minute)
+	if removed := l.DeleteExpired(); removed != 1 || l.Contains("short") {
+		t.Fatalf("sweep removed %d entries, want short only", removed)
+	}
+	clock.Advance(10 * time.Minute)
+	if removed := l.DeleteExpired(); removed != 1 || l.Contains("medium") {
+		t.Fatalf("sweep removed %d entries, want medium only", removed)
+	}
+	clock.Advance(time.Hour)
+	if removed := l.DeleteExpired(); removed != 1 {
+		t.Fatalf("sweep removed %d entries, want long only", removed)
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []string{"forever"}) {
+		t.Fatalf("keys = %v, want [forever]", keys)
+	}
+}
+
+func TestTTLFuncWithoutTTLSweeps(t *testing.T) {
+	clock := newFakeClock()
+	l, err := New[string, int](0,
+		withClock[string, int](clock.Now),
+		WithTTLFunc[string, int](func(value int) time.Duration { return time.Duration(value) * time.Minute }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(l.Close)
+	l.Add("a", 1)
+	l.Add("b", 10)
+	clock.Advance(2 * time.Minute)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for l.PhysicalLen() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("the background sweep did not delete the expired entry, %d entries left", l.PhysicalLen())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !l.Contains("b") {
+		t.Fatal("the background sweep deleted an entry which did not expire")
+	}
+}
+
+func TestRepairBuckets(t *testing.T) {
+	clock := newFakeClock()
+	var evicted []int
+	l := newClockLRU[int, int](t, 0, time.Minute, clock, WithOnEvict(func(key, value int) {
+		evicted = append(evicted, key)
+	}))
+	for i := 0; i < 3; i++ {
+		l.Add(i, i)
+	}
+	if n := l.RepairBuckets(); n != 0 {
+		t.Fatalf("RepairBuckets of a consistent cache = %d, want 0", n)
+	}
+
+	// key 1 leaves the map and the list but stays in its bucket
+	orphan := l.entries[1]
+	l.evictList.Remove(orphan)
+	delete(l.entries, 1)
+	// key 2 leaves its bucket but stays in the cache
+	delete(l.buckets[l.entries[2].Bucket].entries, 2)
+
+	if n := l.RepairBuckets(); n != 1 {
+		t.Fatalf("RepairBuckets = %d, want 1", n)
+	}
+	for i, b := range l.buckets {
+		if b.entries[1] != nil {
+			t.Fatalf("the orphan is still in bucket %d", i)
+		}
+	}
+	if entry := l.entries[2]; l.buckets[entry.Bucket].entries[2] != entry {
+		t.Fatal("key 2 was not put back into its bucket")
+	}
+
+	// the sweep deletes the remaining entries once, and not the orphan
+	clock.Advance(2 * time.Minute)
+	if removed := l.DeleteExpired(); removed != 2 {
+		t.Fatalf("sweep removed %d entries, want 2", removed)
+	}
+	slices.Sort(evicted)
+	if !slices.Equal(evicted, []int{0, 2}) {
+		t.Fatalf("evicted %v, want [0 2]", evicted)
+	}
+	if n := l.RepairBuckets(); n != 0 {
+		t.Fatalf("RepairBuckets after the sweep = %d, want 0", n)
+	}
+}
+
+func TestSweepNotHeldUpByLongTTL(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[int, int](t, 0, time.Second, clock)
+	bucket := l.nextBucket
+	for i := 0; i < 5; i++ {
+		l.Add(i, i)
+	}
+	l.AddWithTTL(100, 100, time.Hour)
+	if int(l.entries[100].Bucket) != int(bucket) {
+		t.Fatal("the long TTL entry did not share the bucket of the short ones")
+	}
+	clock.Advance(2 * time.Second)
+
+	start := time.Now()
+	l.deleteExpired()
+	if elapsed := time.Since(start); elapsed > 10*l.sweepInterval() {
+		t.Fatalf("sweep of the bucket took %v, want about the sweep interval %v", elapsed, l.sweepInterval())
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []int{100}) {
+		t.Fatalf("keys after the sweep = %v, want the long TTL entry only", keys)
+	}
+	if !l.buckets[bucket].newestEntry.Equal(l.entries[100].ExpiresAt) {
+		t.Fatal("the bucket does not wait for the long TTL entry in the next round")
+	}
+	if l.nextBucket == bucket {
+		t.Fatal("the sweep did not move on to the next bucket")
+	}
+}
+
+func TestRangeByExpiry(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, int](t, 0, time.Hour, clock)
+	l.AddWithTTL("c", 3, 30*time.Minute)
+	l.AddWithTTL("a", 1, 10*time.Minute)
+	l.AddWithTTL("expired", 0, time.Minute)
+	l.AddWithTTL("b", 2, 20*time.Minute)
+	clock.Advance(2 * time.Minute)
+
+	var keys []string
+	var prev time.Time
+	l.RangeByExpiry(func(key string, value int, expiresAt time.Time) bool {
+		if expiresAt.Before(prev) {
+			t.Fatalf("%s expiring at %v visited after an entry expiring at %v", key, expiresAt, prev)
+		}
+		prev = expiresAt
+		keys = append(keys, key)
+		return true
+	})
+	if !slices.Equal(keys, []string{"a", "b", "c"}) {
+		t.Fatalf("visited %v, want [a b c]", keys)
+	}
+
+	keys = keys[:0]
+	l.RangeByExpiry(func(key string, value int, expiresAt time.Time) bool {
+		keys = append(keys, key)
+		return len(keys) < 2
+	})
+	if !slices.Equal(keys, []string{"a", "b"}) {
+		t.Fatalf("visited %v before stopping, want [a b]", keys)
+	}
+}
+
+func TestAddEvictsExpiredFirst(t *testing.T) {
+	clock := newFakeClock()
+	reasons := make(map[string]EvictReason)
+	l := newClockLRU[string, int](t, 2, time.Minute, clock, WithEvictReasonCallback(func(key string, value int, reason EvictReason) {
+		reasons[key] = reason
+	}))
+	l.AddWithTTL("oldest", 1, time.Hour)
+	clock.Advance(time.Second)
+	l.Add("short", 2)
+	// the buckets went half way round, so the new entry doesn't land in the bucket of short
+	clock.Advance(90 * time.Second)
+
+	// short expired, so it goes instead of the live oldest entry
+	l.Add("new", 3)
+	if reason, ok := reasons["short"]; !ok || reason != Expired {
+		t.Fatalf("short left with %v (%v), want Expired", reason, ok)
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []string{"oldest", "new"}) {
+		t.Fatalf("keys = %v, want [oldest new]", keys)
+	}
+
+	// without expired entries the oldest one is evicted
+	l.Add("newer", 4)
+	if reason, ok := reasons["oldest"]; !ok || reason != Evicted {
+		t.Fatalf("oldest left with %v (%v), want Evicted", reason, ok)
+	}
+}
+
+func TestContainsMany(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, int](t, 0, time.Hour, clock)
+	l.Add("present", 1)
+	l.AddWithTTL("expired", 2, time.Minute)
+	clock.Advance(2 * time.Minute)
+
+	keys := []string{"present", "absent", "expired"}
+	if got := l.ContainsMany(keys); !slices.Equal(got, []bool{true, false, false}) {
+		t.Fatalf("ContainsMany = %v, want [true false false]", got)
+	}
+	if got := l.MissingKeys(keys); !slices.Equal(got, []string{"absent", "expired"}) {
+		t.Fatalf("MissingKeys = %v, want [absent expired]", got)
+	}
+}
+
+func TestMaxExpirationLatency(t *testing.T) {
+	tests := []struct {
+		ttl     time.Duration
+		buckets uint8
+		want    time.Duration
+	}{
+		{ttl: time.Minute, buckets: 60, want: time.Second},
+		{ttl: 10 * time.Second, buckets: 4, want: 2500 * time.Millisecond},
+		{ttl: time.Hour, buckets: 1, want: time.Hour},
+	}
+	for _, tt := range tests {
+		l := NewLRU[int, int](0, nil, tt.ttl, WithBuckets[int, int](tt.buckets))
+		if got := l.MaxExpirationLatency(); got != tt.want || got != tt.ttl/time.Duration(len(l.buckets)) {
+			t.Errorf("MaxExpirationLatency with TTL %v and %d buckets = %v, want %v", tt.ttl, tt.buckets, got, tt.want)
+		}
+		l.Close()
+	}
+	// without a given number of buckets it is the TTL divided by the default one
+	l := NewLRU[int, int](0, nil, time.Minute)
+	defer l.Close()
+	if got := l.MaxExpirationLatency(); got != time.Minute/defaultNumBuckets {
+		t.Errorf("MaxExpirationLatency = %v, want %v", got, time.Minute/defaultNumBuckets)
+	}
+}
+
+func TestExpirationEnabled(t *testing.T) {
+	off := NewLRU[int, int](0, nil, 0)
+	defer off.Close()
+	if off.ExpirationEnabled() {
+		t.Fatal("ExpirationEnabled without a TTL = true")
+	}
+
+	l := NewLRU[int, int](0, nil, time.Minute)
+	if !l.ExpirationEnabled() {
+		t.Fatal("ExpirationEnabled with a TTL = false")
+	}
+	l.Close()
+	if l.ExpirationEnabled() {
+		t.Fatal("ExpirationEnabled after Close = true")
+	}
+	l.Close()
+
+	noSweep := NewLRU[int, int](0, nil, time.Minute, WithoutBackgroundSweep[int, int]())
+	defer noSweep.Close()
+	if noSweep.ExpirationEnabled() {
+		t.Fatal("ExpirationEnabled without the background sweep = true")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	reasons := make(map[string]EvictReason)
+	l := NewLRU[string, int](0, nil, time.Hour, WithEvictReasonCallback(func(key string, value int, reason EvictReason) {
+		reasons[key] = reason
+	}))
+	defer l.Close()
+	l.Add("expired", 1)
+	l.Add("removed", 2)
+
+	if !l.Expire("expired") {
+		t.Fatal("Expire of a present key = false")
+	}
+	if l.Expire("missing") {
+		t.Fatal("Expire of a missing key = true")
+	}
+	l.Remove("removed")
+	if reasons["expired"] != Expired || reasons["removed"] != Removed {
+		t.Fatalf("reasons = %v, want expired: Expired, removed: Removed", reasons)
+	}
+	if l.Len() != 0 {
+		t.Fatalf("len = %d, want 0", l.Len())
+	}
+}
+
+func TestNumBuckets(t *testing.T) {
+	tests := []struct {
+		name     string
+		ttl      time.Duration
+		buckets  int
+		interval time.Duration
+	}{
+		{"shorter than the minimum interval", 5 * time.Millisecond, 1, 5 * time.Millisecond},
+		{"short", 100 * time.Millisecond, 10, 10 * time.Millisecond},
+		{"medium", time.Minute, 100, 600 * time.Millisecond},
+		{"long", 2 * time.Minute, 120, time.Second},
+		{"longer than the maximum buckets allow", time.Hour, 256, time.Hour / 256},
+		{"no ttl", 0, 1, noEvictionTTL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLRU[int, int](0, nil, tt.ttl)
+			defer l.Close()
+			if len(l.buckets) != tt.buckets {
+				t.Fatalf("%d buckets, want %d", len(l.buckets), tt.buckets)
+			}
+			if interval := l.sweepInterval(); interval != tt.interval {
+				t.Fatalf("sweep interval %v, want %v", interval, tt.interval)
+			}
+		})
+	}
+}
+
+func TestExtendAfterAccesses(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, int](t, 0, time.Minute, clock, WithExtendAfterAccesses[string, int](3))
+	l.Add("cold", 1)
+	l.Add("hot", 2)
+	start := clock.Now()
+
+	// below the threshold reads don't extend the TTL
+	clock.Advance(20 * time.Second)
+	for i := 0; i < 2; i++ {
+		if _, expiresAt, _ := l.GetWithExpiry("hot"); !expiresAt.Equal(start.Add(time.Minute)) {
+			t.Fatalf("read %d extended the TTL to %v", i+1, expiresAt)
+		}
+	}
+	l.Get("cold")
+	// the third read reaches it and every read from then on extends it
+	clock.Advance(20 * time.Second)
+	if _, expiresAt, _ := l.GetWithExpiry("hot"); !expiresAt.Equal(clock.Now().Add(time.Minute)) {
+		t.Fatalf("read at the threshold expires at %v, want %v", expiresAt, clock.Now().Add(time.Minute))
+	}
+
+	clock.Advance(30 * time.Second)
+	if _, ok := l.Get("cold"); ok {
+		t.Fatal("entry read below the threshold did not expire")
+	}
+	for range 5 {
+		if _, ok := l.Get("hot"); !ok {
+			t.Fatalf("hot entry expired at %v", clock.Now().Sub(start))
+		}
+		clock.Advance(50 * time.Second)
+	}
+}
+
+func TestExtendAfterAccessesRequiresTTL(t *testing.T) {
+	if _, err := New[int, int](0, WithExtendAfterAccesses[int, int](2)); err == nil {
+		t.Fatal("New with WithExtendAfterAccesses and no TTL returned no error")
+	}
+}
+
+func TestGracePeriod(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, int](t, 0, time.Minute, clock, WithGracePeriod[string, int](10*time.Second))
+	l.Add("k", 1)
+	expiresAt := clock.Now().Add(time.Minute)
+
+	clock.Advance(time.Minute + 5*time.Second)
+	value, at, ok := l.GetWithExpiry("k")
+	if !ok || value != 1 {
+		t.Fatalf("Get during the grace period = %d, %v, want 1, true", value, ok)
+	}
+	// the expiration time in the past flags the value as stale
+	if !at.Equal(expiresAt) || !at.Before(clock.Now()) {
+		t.Fatalf("stale value expires at %v, want %v", at, expiresAt)
+	}
+	if removed := l.DeleteExpired(); removed != 0 {
+		t.Fatalf("sweep during the grace period removed %d entries, want 0", removed)
+	}
+
+	clock.Advance(5 * time.Second)
+	if _, ok := l.Get("k"); !ok {
+		t.Fatal("Get at the end of the grace period missed")
+	}
+	clock.Advance(time.Nanosecond)
+	if _, ok := l.Get("k"); ok {
+		t.Fatal("Get after the grace period hit")
+	}
+	if l.PhysicalLen() != 0 {
+		t.Fatal("the entry missed after the grace period was not deleted")
+	}
+}
+
+func TestGracePeriodRequiresTTL(t *testing.T) {
+	if _, err := New[int, int](0, WithGracePeriod[int, int](time.Second)); err == nil {
+		t.Fatal("New with WithGracePeriod and no TTL returned no error")
+	}
+}
+
+func TestAddWithTTL(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, int](t, 0, time.Second, clock)
+	l.AddWithTTL("short", 1, 500*time.Millisecond)
+	l.AddWithTTL("long", 2, time.Hour)
+	l.AddWithTTL("forever", 3, 0)
+
+	clock.Advance(2 * time.Second)
+	// a full round of the sweep deletes only what expired, although the long TTL entry
+	// shares the bucket of the short one
+	for range l.buckets {
+		l.deleteExpired()
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []string{"long", "forever"}) {
+		t.Fatalf("keys after a sweep round = %v, want [long forever]", keys)
+	}
+	if l.PhysicalLen() != 2 {
+		t.Fatalf("PhysicalLen = %d, want 2 as short was deleted", l.PhysicalLen())
+	}
+
+	clock.Advance(time.Hour)
+	if _, ok := l.Get("long"); ok {
+		t.Fatal("long TTL entry did not expire")
+	}
+	if _, ok := l.Get("forever"); !ok {
+		t.Fatal("entry added with 0 TTL expired")
+	}
+}
+
+func TestGetWithExpiry(t *testing.T) {
+	l := NewLRU[string, int](0, nil, time.Minute)
+	defer l.Close()
+	before := time.Now()
+	l.Add("a", 1)
+	l.AddWithTTL("b", 2, time.Hour)
+	after := time.Now()
+
+	value, expiresAt, ok := l.GetWithExpiry("b")
+	if !ok || value != 2 {
+		t.Fatalf("GetWithExpiry = %d, %v, want 2, true", value, ok)
+	}
+	if expiresAt.Before(before.Add(time.Hour)) || expiresAt.After(after.Add(time.Hour)) {
+		t.Fatalf("expires at %v, want between %v and %v", expiresAt, before.Add(time.Hour), after.Add(time.Hour))
+	}
+	if _, expiresAt, ok := l.GetWithExpiry("missing"); ok || !expiresAt.IsZero() {
+		t.Fatalf("GetWithExpiry of a missing key = %v, %v, want zero time, false", expiresAt, ok)
+	}
+
+	// Get promotes the key, Peek doesn't
+	l.GetWithExpiry("a")
+	if keys := l.Keys(); !slices.Equal(keys, []string{"b", "a"}) {
+		t.Fatalf("keys after GetWithExpiry = %v, want [b a]", keys)
+	}
+	if _, expiresAt, ok := l.PeekWithExpiry("b"); !ok || expiresAt.Sub(before) < time.Hour {
+		t.Fatalf("PeekWithExpiry = %v, %v, want the expiration time of b", expiresAt, ok)
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []string{"b", "a"}) {
+		t.Fatalf("keys after PeekWithExpiry = %v, want [b a]", keys)
+	}
+}
+
+func TestGetWithExpiryExpired(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, int](t, 0, time.Minute, clock)
+	l.Add("a", 1)
+	l.Add("b", 2)
+	clock.Advance(2 * time.Minute)
+	if value, expiresAt, ok := l.GetWithExpiry("a"); ok || value != 0 || !expiresAt.IsZero() {
+		t.Fatalf("GetWithExpiry of an expired key = %d, %v, %v, want 0, zero time, false", value, expiresAt, ok)
+	}
+	if value, expiresAt, ok := l.PeekWithExpiry("b"); ok || value != 0 || !expiresAt.IsZero() {
+		t.Fatalf("PeekWithExpiry of an expired key = %d, %v, %v, want 0, zero time, false", value, expiresAt, ok)
+	}
+}
+
+func TestExtendIf(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, bool](t, 0, time.Minute, clock)
+	// the value tells whether the session is premium
+	l.Add("alice", true)
+	l.Add("bob", false)
+	l.Add("carol", true)
+	l.AddWithTTL("dave", true, time.Second)
+	clock.Advance(30 * time.Second)
+
+	isPremium := func(key string, premium bool) bool { return premium }
+	if extended := l.ExtendIf(isPremium, time.Hour); extended != 2 {
+		t.Fatalf("ExtendIf = %d, want 2 as dave expired", extended)
+	}
+
+	clock.Advance(time.Minute)
+	l.DeleteExpired()
+	if keys := l.Keys(); !slices.Equal(keys, []string{"alice", "carol"}) {
+		t.Fatalf("keys after the sweep = %v, want [alice carol]", keys)
+	}
+	if _, expiresAt, _ := l.PeekWithExpiry("alice"); !expiresAt.Equal(clock.Now().Add(-time.Minute).Add(time.Hour)) {
+		t.Fatalf("extended entry expires at %v", expiresAt)
+	}
+}
+
+func TestLongestLived(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, int](t, 0, time.Minute, clock)
+	if _, _, _, ok := l.LongestLived(); ok {
+		t.Fatal("LongestLived of an empty cache = true")
+	}
+	l.AddWithTTL("a", 1, 10*time.Minute)
+	clock.Advance(time.Minute)
+	l.AddWithTTL("b", 2, 5*time.Minute)
+	l.Add("c", 3)
+	key, value, expiresAt, ok := l.LongestLived()
+	if !ok || key != "a" || value != 1 || !expiresAt.Equal(clock.Now().Add(9*time.Minute)) {
+		t.Fatalf("LongestLived = %s, %d, %v, %v, want a, 1, %v, true", key, value, expiresAt, ok, clock.Now().Add(9*time.Minute))
+	}
+
+	clock.Advance(9*time.Minute + time.Second)
+	if _, _, _, ok := l.LongestLived(); ok {
+		t.Fatal("LongestLived of expired entries = true")
+	}
+}
+
+func TestAll(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, int](t, 0, time.Minute, clock)
+	l.Add("a", 1)
+	l.AddWithTTL("expired", 2, time.Second)
+	l.Add("b", 3)
+	clock.Advance(2 * time.Second)
+
+	keys := slices.Collect(func(yield func(string) bool) {
+		for key := range l.All() {
+			if !yield(key) {
+				return
+			}
+		}
+	})
+	if !slices.Equal(keys, l.Keys()) || !slices.Equal(keys, []string{"a", "b"}) {
+		t.Fatalf("All yielded %v, want the order of Keys [a b]", keys)
+	}
+}
+
+func TestExpiringWithin(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, int](t, 0, time.Hour, clock)
+	l.AddWithTTL("expired", 0, time.Second)
+	l.AddWithTTL("soon", 1, time.Minute)
+	l.AddWithTTL("edge", 2, 5*time.Minute+time.Second)
+	l.AddWithTTL("later", 3, 10*time.Minute)
+	l.Add("default", 4)
+	clock.Advance(time.Second + time.Nanosecond)
+
+	keys := l.ExpiringWithin(5 * time.Minute)
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"edge", "soon"}) {
+		t.Fatalf("ExpiringWithin = %v, want [edge soon]", keys)
+	}
+	if keys := l.ExpiringWithin(0); len(keys) != 0 {
+		t.Fatalf("ExpiringWithin(0) = %v, want none", keys)
+	}
+}
+
+func TestSoftExpiry(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, int](t, 0, time.Minute, clock, WithSoftExpiry[string, int]())
+	l.Add("k", 1)
+
+	clock.Advance(time.Minute + time.Second)
+	if _, ok := l.Get("k"); ok {
+		t.Fatal("Get of a soft expired entry hit")
+	}
+	// the miss of Get doesn't delete the entry
+	if value, ok := l.Peek("k"); !ok || value != 1 {
+		t.Fatalf("Peek of a soft expired entry = %d, %v, want 1, true", value, ok)
+	}
+	if removed := l.DeleteExpired(); removed != 1 {
+		t.Fatalf("sweep removed %d entries, want 1", removed)
+	}
+	if _, ok := l.Peek("k"); ok {
+		t.Fatal("Peek after the sweep hit")
+	}
+}
+
+func TestSoftExpiryRequiresTTL(t *testing.T) {
+	if _, err := New[int, int](0, WithSoftExpiry[int, int]()); err == nil {
+		t.Fatal("New with WithSoftExpiry and no TTL returned no error")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	clock := newFakeClock()
+	src := newClockLRU[string, int](t, 0, time.Hour, clock)
+	src.AddWithTTL("short", 1, time.Minute)
+	src.Add("a", 2)
+	src.Add("b", 3)
+	src.Get("a")
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// the time between Save and Load doesn't count against the TTL
+	clock.Advance(2 * time.Hour)
+	dst := newClockLRU[string, int](t, 0, time.Hour, clock)
+	if err := dst.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if keys := dst.Keys(); !slices.Equal(keys, []string{"short", "b", "a"}) {
+		t.Fatalf("keys = %v, want [short b a]", keys)
+	}
+	if value, expiresAt, _ := dst.PeekWithExpiry("short"); value != 1 || !expiresAt.Equal(clock.Now().Add(time.Minute)) {
+		t.Fatalf("short = %d expiring at %v, want 1 expiring in a minute", value, expiresAt)
+	}
+
+	// expired entries are not saved
+	clock.Advance(2 * time.Minute)
+	buf.Reset()
+	if err := dst.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	small := newClockLRU[string, int](t, 1, time.Hour, clock)
+	if err := small.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// only the newest fits
+	if keys := small.Keys(); !slices.Equal(keys, []string{"a"}) {
+		t.Fatalf("keys = %v, want [a]", keys)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	clock := newFakeClock()
+	src := newClockLRU[string, int](t, 0, time.Hour, clock)
+	src.AddWithTTL("short", 1, time.Minute)
+	src.Add("a", 2)
+	src.Add("b", 3)
+	src.Get("a")
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newClockLRU[string, int](t, 0, time.Hour, clock)
+	if err := json.Unmarshal(data, dst); err != nil {
+		t.Fatal(err)
+	}
+	if keys := dst.Keys(); !slices.Equal(keys, []string{"short", "b", "a"}) {
+		t.Fatalf("keys = %v, want [short b a]", keys)
+	}
+	// the expiration times are kept
+	for _, key := range dst.Keys() {
+		_, want, _ := src.PeekWithExpiry(key)
+		if _, got, _ := dst.PeekWithExpiry(key); !got.Equal(want) {
+			t.Fatalf("%s expires at %v, want %v", key, got, want)
+		}
+	}
+
+	// entries expired in the meantime are skipped
+	clock.Advance(2 * time.Minute)
+	other := newClockLRU[string, int](t, 0, time.Hour, clock)
+	if err := json.Unmarshal(data, other); err != nil {
+		t.Fatal(err)
+	}
+	if keys := other.Keys(); !slices.Equal(keys, []string{"b", "a"}) {
+		t.Fatalf("keys = %v, want [b a]", keys)
+	}
+
+	var zero LRU[string, int]
+	if err := json.Unmarshal(data, &zero); err == nil {
+		t.Fatal("json.Unmarshal into a zero LRU returned no error")
+	}
+}
+
+func TestGetOrLoadWithTTL(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, int](t, 0, time.Hour, clock)
+	value, err := l.GetOrLoadWithTTL("k", func() (int, time.Duration, error) {
+		return 1, time.Minute, nil
+	})
+	if err != nil || value != 1 {
+		t.Fatalf("GetOrLoadWithTTL() = %d, %v, want 1, nil", value, err)
+	}
+	// the TTL of the loader is used instead of the default one
+	if _, expiresAt, _ := l.PeekWithExpiry("k"); !expiresAt.Equal(clock.Now().Add(time.Minute)) {
+		t.Fatalf("k expires at %v, want in a minute", expiresAt)
+	}
+
+	failure := errors.New("failure")
+	if _, err := l.GetOrLoadWithTTL("bad", func() (int, time.Duration, error) {
+		return 2, time.Minute, failure
+	}); !errors.Is(err, failure) {
+		t.Fatalf("error = %v, want %v", err, failure)
+	}
+	if l.Contains("bad") {
+		t.Fatal("the value of a failed load was added")
+	}
+}
+
+func TestGetOrLoadWithTTLConcurrent(t *testing.T) {
+	l := NewLRU[string, int](0, nil, time.Hour)
+	t.Cleanup(l.Close)
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	loader := func() (int, time.Duration, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return 42, time.Minute, nil
+	}
+
+	const goroutines = 8
+	values := make(chan int, goroutines)
+	var wg sync.WaitGroup
+	load := func() {
+		defer wg.Done()
+		value, err := l.GetOrLoadWithTTL("k", loader)
+		if err != nil {
+			t.Error(err)
+		}
+		values <- value
+	}
+	wg.Add(goroutines)
+	go load()
+	<-started
+	for range goroutines - 1 {
+		go load()
+	}
+	close(release)
+	wg.Wait()
+	close(values)
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("loader called %d times, want 1", n)
+	}
+	for value := range values {
+		if value != 42 {
+			t.Fatalf("value = %d, want 42", value)
+		}
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	var evicted []string
+	l := NewLRU[string, int](0, func(key string, value int) {
+		evicted = append(evicted, key)
+	}, 10*time.Millisecond, WithoutBackgroundSweep[string, int]())
+	t.Cleanup(l.Close)
+	l.Add("a", 1)
+	l.Add("b", 2)
+	l.Add("c", 3)
+
+	time.Sleep(20 * time.Millisecond)
+	if removed := l.DeleteExpired(); removed != 3 {
+		t.Fatalf("DeleteExpired() = %d, want 3", removed)
+	}
+	if l.Len() != 0 || l.PhysicalLen() != 0 {
+		t.Fatalf("Len = %d, PhysicalLen = %d after the sweep, want 0", l.Len(), l.PhysicalLen())
+	}
+	slices.Sort(evicted)
+	if !slices.Equal(evicted, []string{"a", "b", "c"}) {
+		t.Fatalf("evicted %v, want [a b c]", evicted)
+	}
+	if removed := l.DeleteExpired(); removed != 0 {
+		t.Fatalf("second DeleteExpired() = %d, want 0", removed)
+	}
+}
+
+func TestDeleteExpiredWithBackgroundSweep(t *testing.T) {
+	var evicted atomic.Int32
+	l := NewLRU[int, int](0, func(key, value int) {
+		evicted.Add(1)
+	}, time.Millisecond)
+	t.Cleanup(l.Close)
+	const n = 1000
+	for i := range n {
+		l.Add(i, i)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	var removed atomic.Int32
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			removed.Add(int32(l.DeleteExpired()))
+		}()
+	}
+	wg.Wait()
+	// each entry is removed once, by the background sweep or one of the calls
+	if l.PhysicalLen() != 0 || evicted.Load() != n || removed.Load() > n {
+		t.Fatalf("PhysicalLen = %d with %d evictions and %d removed by DeleteExpired, want 0 and %d", l.PhysicalLen(), evicted.Load(), removed.Load(), n)
+	}
+}
+
+func TestAverageResidency(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, int](t, 2, time.Hour, clock)
+	if avg := l.AverageResidency(); avg != 0 {
+		t.Fatalf("AverageResidency before any eviction = %v, want 0", avg)
+	}
+	l.Add("a", 1)
+	clock.Advance(10 * time.Second)
+	l.Add("b", 2)
+	clock.Advance(10 * time.Second)
+	l.Add("c", 3) // evicts a after 20s
+	clock.Advance(30 * time.Second)
+	l.Add("d", 4) // evicts b after 40s
+	if got, want := l.AverageResidency(), 30*time.Second; got != want {
+		t.Fatalf("AverageResidency = %v, want %v", got, want)
+	}
+
+	// removed entries don't count, expired ones do
+	l.Remove("c")
+	clock.Advance(time.Hour + time.Second)
+	if removed := l.DeleteExpired(); removed != 1 {
+		t.Fatalf("DeleteExpired() = %d, want 1", removed)
+	}
+	if got, want := l.AverageResidency(), (20*time.Second+40*time.Second+time.Hour+time.Second)/3; got != want {
+		t.Fatalf("AverageResidency = %v, want %v", got, want)
+	}
+}
+
+func TestZeroValueDeletes(t *testing.T) {
+	for _, deletes := range []bool{false, true} {
+		var opts []Option[string, int]
+		if deletes {
+			opts = append(opts, WithZeroValueDeletes[string, int]())
+		}
+		var reasons []EvictReason
+		opts = append(opts, WithEvictReasonCallback(func(key string, value int, reason EvictReason) {
+			reasons = append(reasons, reason)
+		}))
+		l := newClockLRU[string, int](t, 0, time.Hour, newFakeClock(), opts...)
+		l.Add("k", 1)
+		l.Add("k", 0)
+		l.Add("new", 0)
+
+		value, ok := l.Peek("k")
+		if deletes && (ok || l.Contains("new") || !slices.Equal(reasons, []EvictReason{Removed})) {
+			t.Fatalf("with the option: k = %d, %v and new present %v, evicted for %v, want both removed for Removed",
+				value, ok, l.Contains("new"), reasons)
+		}
+		if !deletes && (!ok || value != 0 || !l.Contains("new") || len(reasons) != 0) {
+			t.Fatalf("without the option: k = %d, %v and new present %v, evicted for %v, want both stored",
+				value, ok, l.Contains("new"), reasons)
+		}
+	}
+}
+
+func TestLazyExpiry(t *testing.T) {
+	clock := newFakeClock()
+	var evicted []string
+	l := NewLRU[string, int](0, func(key string, value int) {
+		evicted = append(evicted, key)
+	}, time.Minute, withClock[string, int](clock.Now), WithoutBackgroundSweep[string, int]())
+	t.Cleanup(l.Close)
+	l.Add("get", 1)
+	l.Add("peek", 2)
+
+	clock.Advance(time.Minute + time.Second)
+	if l.PhysicalLen() != 2 {
+		t.Fatalf("PhysicalLen before any lookup = %d, want 2", l.PhysicalLen())
+	}
+	if _, ok := l.Get("get"); ok {
+		t.Fatal("Get of an expired entry hit")
+	}
+	if l.PhysicalLen() != 1 || !slices.Equal(evicted, []string{"get"}) {
+		t.Fatalf("after Get: PhysicalLen = %d, evicted %v, want 1 and [get]", l.PhysicalLen(), evicted)
+	}
+	if _, ok := l.Peek("peek"); ok {
+		t.Fatal("Peek of an expired entry hit")
+	}
+	if l.PhysicalLen() != 0 || !slices.Equal(evicted, []string{"get", "peek"}) {
+		t.Fatalf("after Peek: PhysicalLen = %d, evicted %v, want 0 and [get peek]", l.PhysicalLen(), evicted)
+	}
+}
+
+func TestTouchTTL(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, int](t, 0, time.Minute, clock)
+	l.Add("a", 1)
+	l.Add("b", 2)
+	l.Add("c", 3)
+
+	clock.Advance(50 * time.Second)
+	if !l.TouchTTL("a") || !l.TouchTTLWith("b", 0) {
+		t.Fatal("touch of a present key = false")
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []string{"c", "a", "b"}) {
+		t.Fatalf("keys = %v, want the touched keys newest [c a b]", keys)
+	}
+
+	// the touched entries moved between the buckets
+	if orphans := l.RepairBuckets(); orphans != 0 {
+		t.Fatalf("RepairBuckets() = %d, want 0", orphans)
+	}
+	clock.Advance(30 * time.Second)
+	if removed := l.DeleteExpired(); removed != 1 {
+		t.Fatalf("DeleteExpired() = %d, want 1", removed)
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []string{"a", "b"}) {
+		t.Fatalf("keys = %v, want [a b] as c expired", keys)
+	}
+	if _, expiresAt, _ := l.PeekWithExpiry("a"); !expiresAt.Equal(clock.Now().Add(30 * time.Second)) {
+		t.Fatalf("a expires at %v, want in 30s", expiresAt)
+	}
+
+	clock.Advance(time.Hour)
+	if l.TouchTTL("a") || l.TouchTTL("missing") {
+		t.Fatal("touch of an expired or missing key = true")
+	}
+	if !l.Contains("b") {
+		t.Fatal("b touched with 0 TTL expired")
+	}
+}
+
+func TestSlidingExpiration(t *testing.T) {
+	for _, sliding := range []bool{false, true} {
+		clock := newFakeClock()
+		var opts []Option[string, int]
+		if sliding {
+			opts = append(opts, WithSlidingExpiration[string, int]())
+		}
+		l := newClockLRU[string, int](t, 0, time.Minute, clock, opts...)
+		l.Add("hot", 1)
+		l.Add("cold", 2)
+
+		// the hot key is read every 30s for 10 minutes
+		hits := 0
+		for range 20 {
+			clock.Advance(30 * time.Second)
+			if _, ok := l.Get("hot"); ok {
+				hits++
+			}
+			l.DeleteExpired()
+		}
+		if l.Contains("cold") {
+			t.Fatalf("sliding %v: the key which was never read didn't expire", sliding)
+		}
+		if sliding && (hits != 20 || !l.Contains("hot")) {
+			t.Fatalf("sliding: the hot key expired after %d reads", hits)
+		}
+		// absolute expiration is the default, the entry is still there at its expiration time
+		if !sliding && (hits != 2 || l.Contains("hot")) {
+			t.Fatalf("absolute: the hot key was read %d times, want twice before it expired", hits)
+		}
+	}
+}
+
+// countingObserver counts the events of each kind.
+type countingObserver struct {
+	mu                         sync.Mutex
+	hits, misses, evicts, adds int
+}
+
+func (o *countingObserver) OnHit(key string) {
+	o.mu.Lock()
+	o.hits++
+	o.mu.Unlock()
+}
+
+func (o *countingObserver) OnMiss(key string) {
+	o.mu.Lock()
+	o.misses++
+	o.mu.Unlock()
+}
+
+func (o *countingObserver) OnEvict(key string, value int) {
+	o.mu.Lock()
+	o.evicts++
+	o.mu.Unlock()
+}
+
+func (o *countingObserver) OnAdd(key string) {
+	o.mu.Lock()
+	o.adds++
+	o.mu.Unlock()
+}
+
+func TestObserver(t *testing.T) {
+	clock := newFakeClock()
+	observer := &countingObserver{}
+	l := newClockLRU[string, int](t, 2, time.Minute, clock, WithObserver[string, int](observer))
+	l.Add("a", 1)
+	l.Add("b", 2)
+	l.Add("a", 3)
+	l.Add("c", 4) // evicts b
+	l.Get("a")
+	l.Peek("c")
+	l.Get("b")
+	l.Remove("a")
+
+	// the lookup of an expired entry misses and deletes it
+	clock.Advance(2 * time.Minute)
+	l.Get("c")
+
+	if observer.adds != 4 || observer.hits != 2 || observer.misses != 2 || observer.evicts != 3 {
+		t.Fatalf("adds %d, hits %d, misses %d, evicts %d, want 4, 2, 2, 3",
+			observer.adds, observer.hits, observer.misses, observer.evicts)
+	}
+}
+
+func TestCopyOnGet(t *testing.T) {
+	for _, copied := range []bool{false, true} {
+		var opts []Option[string, []int]
+		if copied {
+			opts = append(opts, WithCopyOnGet[string, []int](slices.Clone[[]int]))
+		}
+		l := newClockLRU[string, []int](t, 0, time.Hour, newFakeClock(), opts...)
+		l.Add("k", []int{1, 2, 3})
+
+		got, _ := l.Get("k")
+		got[0] = 10
+		peeked, _ := l.Peek("k")
+		peeked[1] = 20
+
+		want := []int{10, 20, 3}
+		if copied {
+			want = []int{1, 2, 3}
+		}
+		if values := l.Values(); !slices.Equal(values[0], want) {
+			t.Fatalf("copy on get %v: cached value %v, want %v", copied, values[0], want)
+		}
+	}
+}
+
+func TestBucketOccupancy(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[int, int](t, 0, time.Minute, clock)
+	// an entry every half sweep interval, spread over the whole TTL
+	for i := 0; i < 200; i++ {
+		l.Add(i, i)
+		clock.Advance(300 * time.Millisecond)
+	}
+	occupancy := l.BucketOccupancy()
+	if len(occupancy) != 100 {
+		t.Fatalf("%d buckets, want 100", len(occupancy))
+	}
+	total := 0
+	for _, n := range occupancy {
+		total += n
+	}
+	if total != 200 || slices.Max(occupancy)-slices.Min(occupancy) > 1 {
+		t.Fatalf("occupancy %v holds %d entries, want 200 evenly spread", occupancy, total)
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	ttlFunc := func(value int) time.Duration { return time.Minute }
+	tests := []struct {
+		name    string
+		size    int
+		opts    []Option[string, int]
+		wantErr bool
+	}{
+		{"no options", 0, nil, false},
+		{"ttl and sliding", 2, []Option[string, int]{WithTTL[string, int](time.Minute), WithSlidingExpiration[string, int]()}, false},
+		{"ttl func and grace period", 2, []Option[string, int]{WithTTLFunc[string](ttlFunc), WithGracePeriod[string, int](time.Second)}, false},
+		{"negative size", -1, nil, true},
+		{"negative ttl", 2, []Option[string, int]{WithTTL[string, int](-time.Second)}, true},
+		{"sliding without ttl", 2, []Option[string, int]{WithSlidingExpiration[string, int]()}, true},
+		{"no sweep without ttl", 2, []Option[string, int]{WithoutBackgroundSweep[string, int]()}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := New(tt.size, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil {
+				l.Close()
+			}
+		})
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	clock := newFakeClock()
+	var evicted []string
+	observer := &countingObserver{}
+	l, err := New(2,
+		WithOnEvict(func(key string, value int) {
+			evicted = append(evicted, key)
+		}),
+		WithTTL[string, int](time.Minute),
+		WithSlidingExpiration[string, int](),
+		WithObserver[string, int](observer),
+		withClock[string, int](clock.Now),
+		WithoutBackgroundSweep[string, int]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.Add("a", 1)
+	l.Add("b", 2)
+	clock.Advance(50 * time.Second)
+	l.Get("a")
+	clock.Advance(50 * time.Second)
+
+	// b expired after the TTL, a slid on being read
+	if removed := l.DeleteExpired(); removed != 1 || !l.Contains("a") {
+		t.Fatalf("DeleteExpired() = %d with a present %v, want 1 and true", removed, l.Contains("a"))
+	}
+	l.Add("c", 3)
+	l.Add("d", 4) // evicts a
+	if !slices.Equal(evicted, []string{"b", "a"}) || observer.adds != 4 || observer.hits != 1 {
+		t.Fatalf("evicted %v with %d adds and %d hits observed, want [b a], 4 and 1", evicted, observer.adds, observer.hits)
+	}
+}
+
+func TestWithBuckets(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, int](t, 0, time.Minute, clock, WithBuckets[string, int](4))
+	if len(l.buckets) != 4 {
+		t.Fatalf("%d buckets, want 4", len(l.buckets))
+	}
+	if interval := l.sweepInterval(); interval != 15*time.Second {
+		t.Fatalf("sweep interval %v, want 15s", interval)
+	}
+
+	l.Add("a", 1)
+	clock.Advance(20 * time.Second)
+	l.Add("b", 2)
+	if occupancy := l.BucketOccupancy(); !slices.Equal(occupancy, []int{1, 1, 0, 0}) {
+		t.Fatalf("occupancy %v, want [1 1 0 0]", occupancy)
+	}
+	clock.Advance(time.Minute + time.Second)
+	// a round of the sweep deletes the expired entries of every bucket
+	for range l.buckets {
+		l.deleteExpired()
+	}
+	if l.PhysicalLen() != 0 {
+		t.Fatalf("PhysicalLen after the sweep = %d, want 0", l.PhysicalLen())
+	}
+
+	if _, err := New(0, WithTTL[int, int](time.Minute), WithBuckets[int, int](0)); err == nil {
+		t.Fatal("New with 0 buckets returned no error")
+	}
+}
+
+func TestLenExcludesExpired(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[string, int](t, 0, time.Minute, clock)
+	l.AddWithTTL("short", 1, time.Second)
+	l.Add("a", 2)
+	l.Add("b", 3)
+	if l.Len() != 3 || len(l.Keys()) != 3 {
+		t.Fatalf("Len = %d with %d keys, want 3", l.Len(), len(l.Keys()))
+	}
+
+	// right after expiring, before any sweep or lookup
+	clock.Advance(time.Second + time.Nanosecond)
+	if l.Len() != len(l.Keys()) || l.Len() != 2 {
+		t.Fatalf("Len = %d with %d keys, want 2", l.Len(), len(l.Keys()))
+	}
+	if l.PhysicalLen() != 3 {
+		t.Fatalf("PhysicalLen = %d, want 3 as nothing was deleted", l.PhysicalLen())
+	}
+
+	clock.Advance(time.Minute)
+	if l.Len() != 0 || len(l.Keys()) != 0 {
+		t.Fatalf("Len = %d with %d keys, want 0", l.Len(), len(l.Keys()))
+	}
+}
+
+func TestLenCountsExpiredOnce(t *testing.T) {
+	clock := newFakeClock()
+	l := newClockLRU[int, int](t, 0, time.Minute, clock)
+	for i := 0; i < 6; i++ {
+		l.AddWithTTL(i, i, time.Duration(i+1)*time.Second)
+	}
+	checkLen := func(want int) {
+		t.Helper()
+		if l.Len() != want || len(l.Keys()) != want {
+			t.Fatalf("Len = %d with %d keys, want %d", l.Len(), len(l.Keys()), want)
+		}
+		if dead := l.DeadEstimate(); dead != l.PhysicalLen()-want {
+			t.Fatalf("DeadEstimate = %d with PhysicalLen %d, want %d", dead, l.PhysicalLen(), l.PhysicalLen()-want)
+		}
+	}
+
+	// 0, 1 and 2 expired, asking twice doesn't count them again
+	clock.Advance(3*time.Second + time.Nanosecond)
+	checkLen(3)
+	checkLen(3)
+
+	// an expired key added again, a live key refreshed and an expired key removed
+	l.Add(0, 0)
+	l.RefreshMany([]int{3}, time.Hour)
+	l.Remove(1)
+	checkLen(4)
+
+	// 4 and 5 expired, 0 and 3 didn't
+	clock.Advance(10 * time.Second)
+	checkLen(2)
+	if l.DeleteExpired() != 3 {
+		t.Fatal("DeleteExpired didn't delete 2, 4 and 5")
+	}
+	checkLen(2)
+
+	l.Purge()
+	checkLen(0)
+	l.Add(7, 7)
+	checkLen(1)
+}
@@ -0,0 +1,101 @@
+package expirable_lru
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+// savedEntry is an entry of the cache as written by Save, with the TTL left instead of the expiration time,
+// so the time between Save and Load doesn't count.
+type savedEntry[K comparable, V any] struct {
+	Key         K
+	Value       V
+	CreatedAt   time.Time
+	TTL         time.Duration
+	AccessCount uint64
+}
+
+// Save writes the non-expired entries of the cache with their remaining TTL, from oldest to newest,
+// to w with encoding/gob. Restore them with Load. The keys and values have to be encodable by encoding/gob.
+func (l *LRU[K, V]) Save(w io.Writer) error {
+	exported := l.Export()
+	now := l.now()
+	entries := make([]savedEntry[K, V], len(exported))
+	for i, e := range exported {
+		entries[i] = savedEntry[K, V]{
+			Key:         e.Key,
+			Value:       e.Value,
+			CreatedAt:   e.CreatedAt,
+			TTL:         e.ExpiresAt.Sub(now),
+			AccessCount: e.AccessCount,
+		}
+	}
+	return gob.NewEncoder(w).Encode(entries)
+}
+
+// Load reads the entries written by Save from r and adds them from oldest to newest,
+// restoring their recency order, each expiring after the TTL it had left when saved.
+// If they don't fit, the oldest ones are evicted.
+func (l *LRU[K, V]) Load(r io.Reader) error {
+	var saved []savedEntry[K, V]
+	if err := gob.NewDecoder(r).Decode(&saved); err != nil {
+		return err
+	}
+	now := l.now()
+	entries := make([]ExportedEntry[K, V], 0, len(saved))
+	for _, e := range saved {
+		if e.TTL <= 0 {
+			continue
+		}
+		entries = append(entries, ExportedEntry[K, V]{
+			Key:         e.Key,
+			Value:       e.Value,
+			CreatedAt:   e.CreatedAt,
+			ExpiresAt:   now.Add(e.TTL),
+			AccessCount: e.AccessCount,
+		})
+	}
+	l.Import(entries)
+	return nil
+}
+
+// jsonEntry is an entry of the cache as encoded in JSON.
+type jsonEntry[K comparable, V any] struct {
+	Key       K         `json:"key"`
+	Value     V         `json:"value"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// MarshalJSON encodes the non-expired entries of the cache as an array of
+// {"key": ..., "value": ..., "expiresAt": ...} objects, from oldest to newest.
+func (l *LRU[K, V]) MarshalJSON() ([]byte, error) {
+	exported := l.Export()
+	entries := make([]jsonEntry[K, V], len(exported))
+	for i, e := range exported {
+		entries[i] = jsonEntry[K, V]{Key: e.Key, Value: e.Value, ExpiresAt: e.ExpiresAt}
+	}
+	return json.Marshal(entries)
+}
+
+// UnmarshalJSON adds the entries encoded by MarshalJSON from oldest to newest, restoring their
+// recency order and expiration times. Entries expired in the meantime are skipped, and if the rest
+// don't fit, the oldest ones are evicted. The cache has to be made by NewLRU.
+func (l *LRU[K, V]) UnmarshalJSON(data []byte) error {
+	if l.buckets == nil {
+		return errors.New("expirable_lru: cache must be made by NewLRU")
+	}
+	var decoded []jsonEntry[K, V]
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	now := l.now()
+	entries := make([]ExportedEntry[K, V], len(decoded))
+	for i, e := range decoded {
+		entries[i] = ExportedEntry[K, V]{Key: e.Key, Value: e.Value, CreatedAt: now, ExpiresAt: e.ExpiresAt}
+	}
+	l.Import(entries)
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+// OnFullnessChange registers fn to be called when the cache becomes full, that is its length
+// reaches its capacity, and when it stops being full. fn is called outside the lock,
+// only on the transitions and not on every change. A nil fn unregisters the callback.
+func (c *Cache[K, V]) OnFullnessChange(fn func(full bool)) {
+	c.lock.Lock()
+	c.onFullnessChange = fn
+	c.full = c.lru.Len() >= c.lru.Cap()
+	c.lock.Unlock()
+}
+
+// checkFullness tracks the peak length and whether the cache is full, returns the callback to call
+// outside the lock if the latter changed, or nil otherwise. Has to be called with lock!
+func (c *Cache[K, V]) checkFullness() func() {
+	length := c.lru.Len()
+	if length > c.maxLen {
+		c.maxLen = length
+	}
+	if c.onFullnessChange == nil {
+		return nil
+	}
+	full := length >= c.lru.Cap()
+	if full == c.full {
+		return nil
+	}
+	c.full = full
+	fn := c.onFullnessChange
+	return func() {
+		fn(full)
+	}
+}
+
+// MaxLen returns the largest number of entries the cache has held since it was created or ResetMaxLen was called.
+func (c *Cache[K, V]) MaxLen() int {
+	c.lock.RLock()
+	maxLen := c.maxLen
+	c.lock.RUnlock()
+	return maxLen
+}
+
+// ResetMaxLen sets the peak number of entries returned by MaxLen to the current length.
+func (c *Cache[K, V]) ResetMaxLen() {
+	c.lock.Lock()
+	c.maxLen = c.lru.Len()
+	c.lock.Unlock()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOnFullnessChange(t *testing.T) {
+	c, err := New[int, int](3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes []bool
+	c.OnFullnessChange(func(full bool) {
+		// called outside the lock, so the cache can be used
+		if full != (c.Len() == 3) {
+			t.Errorf("full = %v with %d entries", full, c.Len())
+		}
+		changes = append(changes, full)
+	})
+
+	for i := 0; i < 5; i++ {
+		c.Add(i, i)
+	}
+	if !slices.Equal(changes, []bool{true}) {
+		t.Fatalf("callbacks after filling = %v, want [true]", changes)
+	}
+	c.Remove(4)
+	c.Remove(3)
+	if !slices.Equal(changes, []bool{true, false}) {
+		t.Fatalf("callbacks after removing = %v, want [true false]", changes)
+	}
+
+	c.OnFullnessChange(nil)
+	c.Add(5, 5)
+	if len(changes) != 2 {
+		t.Fatalf("unregistered callback was called, got %v", changes)
+	}
+}
+
+func TestMaxLen(t *testing.T) {
+	c, err := New[int, int](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 7; i++ {
+		c.Add(i, i)
+	}
+	for i := 0; i < 5; i++ {
+		c.Remove(i)
+	}
+	if c.MaxLen() != 7 {
+		t.Fatalf("MaxLen after draining = %d, want the peak 7", c.MaxLen())
+	}
+	c.ResetMaxLen()
+	if c.MaxLen() != 2 {
+		t.Fatalf("MaxLen after ResetMaxLen = %d, want the current 2", c.MaxLen())
+	}
+	c.Add(10, 10)
+	c.Purge()
+	if c.MaxLen() != 3 {
+		t.Fatalf("MaxLen = %d, want 3", c.MaxLen())
+	}
+	for i := 0; i < 20; i++ {
+		c.Add(i, i)
+	}
+	if c.MaxLen() != 10 {
+		t.Fatalf("MaxLen of a full cache = %d, want the capacity 10", c.MaxLen())
+	}
+}
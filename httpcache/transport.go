@@ -0,0 +1,99 @@
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"lru/expirable_lru"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transport is an http.RoundTripper which caches successful GET and HEAD responses
+// in an expirable LRU for as long as their Cache-Control max-age allows.
+// Responses are keyed by the request method and URL, Vary headers are not taken into account.
+// Call Close when done with it to stop deleting expired responses in the background.
+type Transport struct {
+	// Transport makes the requests which are not answered from the cache.
+	// If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// responses dumped in their wire format, each expiring after its max-age
+	cache *expirable_lru.LRU[string, []byte]
+}
+
+// sweepTTL sets how often the expired responses are deleted, their own TTL is their max-age.
+const sweepTTL = time.Minute
+
+// NewTransport returns a Transport caching up to size responses.
+// Size of 0 makes the cache of unlimited size. Expired responses are deleted
+// in the background within about a minute, until Close is called.
+func NewTransport(size int, transport http.RoundTripper) *Transport {
+	return &Transport{
+		Transport: transport,
+		cache:     expirable_lru.NewLRU[string, []byte](size, nil, sweepTTL),
+	}
+}
+
+// Close stops the goroutine deleting expired responses. The Transport stays usable
+// and still doesn't return expired responses. Calling Close more than once does nothing.
+func (t *Transport) Close() {
+	t.cache.Close()
+}
+
+// RoundTrip returns the cached response for the request if it hasn't expired,
+// otherwise makes the request and caches the response if it allows that.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.Method + " " + req.URL.String()
+	cacheable := req.Method == http.MethodGet || req.Method == http.MethodHead
+	if cacheable {
+		if dump, ok := t.cache.Get(key); ok {
+			return http.ReadResponse(bufio.NewReader(bytes.NewReader(dump)), req)
+		}
+	}
+
+	resp, err := t.transport().RoundTrip(req)
+	if err != nil || !cacheable || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	maxAge, ok := parseMaxAge(resp.Header.Get("Cache-Control"))
+	if !ok {
+		return resp, nil
+	}
+	// reads the body and replaces it with an in-memory copy
+	dump, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	t.cache.AddWithTTL(key, dump, maxAge)
+	return resp, nil
+}
+
+func (t *Transport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+	return http.DefaultTransport
+}
+
+// parseMaxAge returns the max-age of a Cache-Control header value,
+// ok is false if the response must not be cached.
+func parseMaxAge(cacheControl string) (maxAge time.Duration, ok bool) {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store" || directive == "no-cache":
+			return 0, false
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.Atoi(strings.Trim(directive[len("max-age="):], `"`))
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+			maxAge = time.Duration(seconds) * time.Second
+		}
+	}
+	return maxAge, maxAge > 0
+}
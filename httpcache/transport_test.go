@@ -0,0 +1,133 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// stubTransport answers every request with the given status and Cache-Control header,
+// the body being the number of the request.
+type stubTransport struct {
+	status       int
+	cacheControl string
+	calls        int
+}
+
+func (s *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.calls++
+	rec := httptest.NewRecorder()
+	if s.cacheControl != "" {
+		rec.Header().Set("Cache-Control", s.cacheControl)
+	}
+	rec.WriteHeader(s.status)
+	rec.WriteString(strconv.Itoa(s.calls))
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+// get makes a request through the transport and returns the body.
+func get(t *testing.T, transport http.RoundTripper, method, url string) string {
+	t.Helper()
+	req := httptest.NewRequest(method, url, nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestTransportHitAndMiss(t *testing.T) {
+	stub := &stubTransport{status: http.StatusOK, cacheControl: "public, max-age=60"}
+	transport := NewTransport(0, stub)
+	defer transport.Close()
+
+	if body := get(t, transport, http.MethodGet, "http://example.com/a"); body != "1" {
+		t.Fatalf("first response = %q, want %q", body, "1")
+	}
+	if body := get(t, transport, http.MethodGet, "http://example.com/a"); body != "1" || stub.calls != 1 {
+		t.Fatalf("second response = %q after %d calls, want the cached %q", body, stub.calls, "1")
+	}
+	// the method and the URL are part of the key
+	if body := get(t, transport, http.MethodGet, "http://example.com/b"); body != "2" {
+		t.Fatalf("response of another URL = %q, want %q", body, "2")
+	}
+	if body := get(t, transport, http.MethodHead, "http://example.com/a"); stub.calls != 3 {
+		t.Fatalf("HEAD was answered from the cache of GET: %q", body)
+	}
+	get(t, transport, http.MethodPost, "http://example.com/c")
+	get(t, transport, http.MethodPost, "http://example.com/c")
+	if stub.calls != 5 {
+		t.Fatalf("POST responses were cached, %d calls, want 5", stub.calls)
+	}
+}
+
+func TestTransportNotCacheable(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		cacheControl string
+	}{
+		{"no Cache-Control", http.StatusOK, ""},
+		{"no-store", http.StatusOK, "no-store, max-age=60"},
+		{"no-cache", http.StatusOK, "max-age=60, no-cache"},
+		{"zero max-age", http.StatusOK, "max-age=0"},
+		{"invalid max-age", http.StatusOK, "max-age=soon"},
+		{"not OK", http.StatusNotFound, "max-age=60"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubTransport{status: tt.status, cacheControl: tt.cacheControl}
+			transport := NewTransport(0, stub)
+			defer transport.Close()
+			get(t, transport, http.MethodGet, "http://example.com/")
+			if body := get(t, transport, http.MethodGet, "http://example.com/"); body != "2" {
+				t.Fatalf("second response = %q, want a new response %q", body, "2")
+			}
+		})
+	}
+}
+
+func TestTransportMaxAge(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the max-age of a second to pass")
+	}
+	stub := &stubTransport{status: http.StatusOK, cacheControl: `max-age="1"`}
+	transport := NewTransport(0, stub)
+	defer transport.Close()
+	get(t, transport, http.MethodGet, "http://example.com/")
+	if body := get(t, transport, http.MethodGet, "http://example.com/"); body != "1" {
+		t.Fatalf("response within max-age = %q, want the cached %q", body, "1")
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if body := get(t, transport, http.MethodGet, "http://example.com/"); body != "2" {
+		t.Fatalf("response after max-age = %q, want a new response %q", body, "2")
+	}
+}
+
+func TestTransportClose(t *testing.T) {
+	stub := &stubTransport{status: http.StatusOK, cacheControl: "max-age=60"}
+	transport := NewTransport(0, stub)
+	if !transport.cache.ExpirationEnabled() {
+		t.Fatal("expired responses are not deleted in the background")
+	}
+	transport.Close()
+	transport.Close()
+	if transport.cache.ExpirationEnabled() {
+		t.Fatal("the background deletion still runs after Close")
+	}
+	// the transport stays usable
+	get(t, transport, http.MethodGet, "http://example.com/a")
+	if body := get(t, transport, http.MethodGet, "http://example.com/a"); body != "1" || stub.calls != 1 {
+		t.Fatalf("response after Close = %q after %d calls, want the cached %q", body, stub.calls, "1")
+	}
+}
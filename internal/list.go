@@ -20,11 +20,30 @@ type Entry[K comparable, V any] struct {
 	// The Value stored with this element.
 	Value V
 
+	// The time this element was added (optional)
+	CreatedAt time.Time
+
 	// The time this element would be cleaned up (optional)
 	ExpiresAt time.Time
 
+	// The number of times this element was read (optional)
+	AccessCount uint64
+
+	// The time this element was last read, written or touched, i.e. moved to the front (optional)
+	LastAccess time.Time
+
 	// The expiry bucket index this entry was put in (optional)
 	Bucket uint8
+
+	// The position of this element in the heap of entries by expiration time plus one,
+	// 0 if it isn't in the heap and -1 if it was taken out as expired (optional)
+	ExpiryIndex int
+
+	// The cost of Value when it was last written (optional)
+	Cost int64
+
+	// Whether a pointer to Value was handed out, so the element must not be reused for another key (optional)
+	Referenced bool
 }
 
 // PrevEntry returns the previous list element or nil.
@@ -35,6 +54,14 @@ func (e *Entry[K, V]) PrevEntry() *Entry[K, V] {
 	return nil
 }
 
+// NextEntry returns the next list element or nil.
+func (e *Entry[K, V]) NextEntry() *Entry[K, V] {
+	if n := e.next; e.list != nil && n != &e.list.root {
+		return n
+	}
+	return nil
+}
+
 // LRUList represents a doubly linked list.
 // The zero value for LRUList is an empty list ready to use.
 type LRUList[K comparable, V any] struct {
@@ -68,6 +95,14 @@ func (l *LRUList[K, V]) Len() int {
 	return l.len
 }
 
+// Front returns the first element of list l or nil if the list is empty.
+func (l *LRUList[K, V]) Front() *Entry[K, V] {
+	if l.len == 0 {
+		return nil
+	}
+	return l.root.next
+}
+
 // Back returns the last element of list l or nil if the list is empty.
 func (l *LRUList[K, V]) Back() *Entry[K, V] {
 	if l.len == 0 {
@@ -139,6 +174,12 @@ func (l *LRUList[K, V]) PushToFrontExpirable(k K, v V, expiresAt time.Time) *Ent
 	return l.insertValue(k, v, expiresAt, &l.root)
 }
 
+// PushEntryToFront inserts an element e, which doesn't belong to any list, at the front of list l and returns e.
+func (l *LRUList[K, V]) PushEntryToFront(e *Entry[K, V]) *Entry[K, V] {
+	l.lazyInit()
+	return l.insert(e, &l.root)
+}
+
 // MoveToFront moves element e to the front of list l.
 // If e is not an element of l, the list is not modified.
 // The element must not be nil.
@@ -148,3 +189,19 @@ func (l *LRUList[K, V]) MoveToFront(e *Entry[K, V]) {
 	}
 	l.move(e, &l.root)
 }
+
+// MoveToBack moves element e to the back of list l.
+// If e is not an element of l, the list is not modified.
+// The element must not be nil.
+func (l *LRUList[K, V]) MoveToBack(e *Entry[K, V]) {
+	if e.list != l || l.root.prev == e {
+		return
+	}
+	l.move(e, l.root.prev)
+}
+
+// Accessed records a read of element e at the given time.
+func (e *Entry[K, V]) Accessed(now time.Time) {
+	e.AccessCount++
+	e.LastAccess = now
+}
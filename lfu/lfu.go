@@ -0,0 +1,262 @@
+package lfu
+
+import (
+	"fmt"
+	"lru/basic_lru"
+	"lru/internal"
+	"slices"
+	"time"
+)
+
+// LFU has to be a drop-in replacement for basic_lru.LRU.
+var _ basic_lru.LRUCache[int, int] = (*LFU[int, int])(nil)
+
+// EvictCallback is used to get a callback when a cache entry is evicted
+type EvictCallback[K comparable, V any] func(key K, value V)
+
+// LFU implements a non-thread safe fixed size LFU cache.
+// It evicts the least frequently used entry, the least recently used one among the entries
+// used as often. It has the same methods as basic_lru.LRU, where "oldest" stands for
+// the entry evicted next.
+type LFU[K comparable, V any] struct {
+	size    int
+	entries map[K]*internal.Entry[K, V]
+	// lists of the entries by the number of uses, newest at the front
+	freqs   map[uint64]*internal.LRUList[K, V]
+	minFreq uint64
+	onEvict EvictCallback[K, V]
+}
+
+// NewLFU constructs an LFU of the given size
+func NewLFU[K comparable, V any](size int, onEvict EvictCallback[K, V]) (*LFU[K, V], error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid cache size (%d), must be bigger than zero", size)
+	}
+
+	l := &LFU[K, V]{
+		size:    size,
+		entries: make(map[K]*internal.Entry[K, V]),
+		freqs:   make(map[uint64]*internal.LRUList[K, V]),
+		onEvict: onEvict,
+	}
+
+	return l, nil
+}
+
+// Add adds an entry to the cache, returns true if an eviction occurred and
+// counts a use of the key.
+func (l *LFU[K, V]) Add(key K, value V) (evicted bool) {
+	// check for existing entry
+	if entry, ok := l.entries[key]; ok {
+		entry.Value = value
+		l.use(entry)
+		return false
+	}
+
+	evict := len(l.entries) >= l.size
+	if evict {
+		l.removeOldest()
+	}
+
+	// add new entry with no uses
+	entry := l.list(0).PushToFront(key, value)
+	entry.CreatedAt = time.Now()
+	entry.LastAccess = entry.CreatedAt
+	l.entries[key] = entry
+	l.minFreq = 0
+	return evict
+}
+
+// Get returns key's value from the cache and counts a use of the key.
+// ok specifies if the key was found or not.
+func (l *LFU[K, V]) Get(key K) (value V, ok bool) {
+	if entry, ok := l.entries[key]; ok {
+		l.use(entry)
+		return entry.Value, true
+	}
+	return value, false
+}
+
+// Contains checks if a key exists in the cache without counting a use.
+func (l *LFU[K, V]) Contains(key K) (ok bool) {
+	_, ok = l.entries[key]
+	return ok
+}
+
+// Peek returns key's value without counting a use of the key.
+// ok specifies if the key was found or not.
+func (l *LFU[K, V]) Peek(key K) (value V, ok bool) {
+	if entry, ok := l.entries[key]; ok {
+		return entry.Value, true
+	}
+	return value, false
+}
+
+// Remove removes an entry from the cache with the key specified.
+// ok specifies if the key was found or not.
+func (l *LFU[K, V]) Remove(key K) (ok bool) {
+	if entry, ok := l.entries[key]; ok {
+		l.removeEntry(entry)
+		l.fixMinFreq()
+		return true
+	}
+	return false
+}
+
+// RemoveOldest removes the entry evicted next from the cache.
+func (l *LFU[K, V]) RemoveOldest() (key K, value V, ok bool) {
+	if entry := l.oldest(); entry != nil {
+		key, value = entry.Key, entry.Value
+		l.removeEntry(entry)
+		l.fixMinFreq()
+		return key, value, true
+	}
+	return key, value, false
+}
+
+// GetOldest returns the entry evicted next from the cache.
+func (l *LFU[K, V]) GetOldest() (key K, value V, ok bool) {
+	if entry := l.oldest(); entry != nil {
+		return entry.Key, entry.Value, true
+	}
+	return key, value, false
+}
+
+// Keys returns a slice of the keys in the cache, in the order they would be evicted.
+func (l *LFU[K, V]) Keys() []K {
+	keys := make([]K, 0, len(l.entries))
+	l.walk(func(entry *internal.Entry[K, V]) {
+		keys = append(keys, entry.Key)
+	})
+	return keys
+}
+
+// Values returns a slice of the values in the cache, in the order they would be evicted.
+func (l *LFU[K, V]) Values() []V {
+	values := make([]V, 0, len(l.entries))
+	l.walk(func(entry *internal.Entry[K, V]) {
+		values = append(values, entry.Value)
+	})
+	return values
+}
+
+// Len returns the number of entries in the cache.
+func (l *LFU[K, V]) Len() int {
+	return len(l.entries)
+}
+
+// Cap returns the capacity of the cache.
+func (l *LFU[K, V]) Cap() int {
+	return l.size
+}
+
+// Purge clears all the cache entries, invoking the eviction callback in the order they would be evicted.
+func (l *LFU[K, V]) Purge() {
+	if l.onEvict != nil {
+		l.walk(func(entry *internal.Entry[K, V]) {
+			l.onEvict(entry.Key, entry.Value)
+		})
+	}
+	clear(l.entries)
+	clear(l.freqs)
+	l.minFreq = 0
+}
+
+// Resize changes the cache size, returning number of evicted entries.
+func (l *LFU[K, V]) Resize(size int) (evicted int) {
+	diff := l.Len() - size
+	if diff < 0 {
+		diff = 0
+	}
+	for i := 0; i < diff; i++ {
+		l.removeOldest()
+		l.fixMinFreq()
+	}
+	l.size = size
+	return diff
+}
+
+// use counts a use of the entry, moving it to the front of the list of the next frequency.
+func (l *LFU[K, V]) use(entry *internal.Entry[K, V]) {
+	freq := entry.AccessCount
+	l.unlink(entry)
+	entry.Accessed(time.Now())
+	l.list(entry.AccessCount).PushEntryToFront(entry)
+	if _, ok := l.freqs[freq]; !ok && l.minFreq == freq {
+		l.minFreq = entry.AccessCount
+	}
+}
+
+// list returns the list of the entries used freq times, creating it if needed.
+func (l *LFU[K, V]) list(freq uint64) *internal.LRUList[K, V] {
+	list, ok := l.freqs[freq]
+	if !ok {
+		list = internal.NewList[K, V]()
+		l.freqs[freq] = list
+	}
+	return list
+}
+
+// unlink removes the entry from its frequency list, dropping the list if it becomes empty.
+func (l *LFU[K, V]) unlink(entry *internal.Entry[K, V]) {
+	list := l.freqs[entry.AccessCount]
+	list.Remove(entry)
+	if list.Len() == 0 {
+		delete(l.freqs, entry.AccessCount)
+	}
+}
+
+// oldest returns the entry evicted next, the least recently used one of the least frequently used ones.
+func (l *LFU[K, V]) oldest() *internal.Entry[K, V] {
+	if len(l.entries) == 0 {
+		return nil
+	}
+	return l.freqs[l.minFreq].Back()
+}
+
+// walk calls fn for the entries in the order they would be evicted.
+func (l *LFU[K, V]) walk(fn func(entry *internal.Entry[K, V])) {
+	freqs := make([]uint64, 0, len(l.freqs))
+	for freq := range l.freqs {
+		freqs = append(freqs, freq)
+	}
+	slices.Sort(freqs)
+	for _, freq := range freqs {
+		for entry := l.freqs[freq].Back(); entry != nil; entry = entry.PrevEntry() {
+			fn(entry)
+		}
+	}
+}
+
+// removeOldest removes the entry evicted next from the cache. The minimum frequency
+// has to be fixed afterwards, unless an entry with no uses is added.
+func (l *LFU[K, V]) removeOldest() {
+	if entry := l.oldest(); entry != nil {
+		l.removeEntry(entry)
+	}
+}
+
+// removeEntry is used to remove a given entry from the cache.
+// The minimum frequency has to be fixed afterwards.
+func (l *LFU[K, V]) removeEntry(entry *internal.Entry[K, V]) {
+	l.unlink(entry)
+	delete(l.entries, entry.Key)
+	if l.onEvict != nil {
+		l.onEvict(entry.Key, entry.Value)
+	}
+}
+
+// fixMinFreq finds the lowest frequency with entries after removals, 0 if the cache is empty.
+// It walks the frequencies only if the list of the minimum frequency became empty.
+func (l *LFU[K, V]) fixMinFreq() {
+	if _, ok := l.freqs[l.minFreq]; ok {
+		return
+	}
+	l.minFreq = 0
+	first := true
+	for freq := range l.freqs {
+		if first || freq < l.minFreq {
+			l.minFreq, first = freq, false
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package lfu
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHotKeySurvives(t *testing.T) {
+	var evicted []int
+	l, err := NewLFU[int, int](3, func(key, value int) {
+		evicted = append(evicted, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add(0, 0)
+	for range 5 {
+		l.Get(0)
+	}
+	// a scan of many keys read once each doesn't push out the hot key
+	for i := 1; i <= 20; i++ {
+		l.Add(i, i)
+		l.Get(i)
+	}
+	if !l.Contains(0) {
+		t.Fatalf("hot key was evicted, keys %v", l.Keys())
+	}
+	if slices.Contains(evicted, 0) || len(evicted) != 18 {
+		t.Fatalf("evicted %v, want the 18 oldest scanned keys", evicted)
+	}
+}
+
+func TestTiesBrokenByRecency(t *testing.T) {
+	l, err := NewLFU[string, int](3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add("a", 1)
+	l.Add("b", 2)
+	l.Add("c", 3)
+	l.Get("a")
+	l.Get("b")
+	l.Get("c")
+	l.Get("a")
+	// b and c are used as often, b less recently
+	if keys := l.Keys(); !slices.Equal(keys, []string{"b", "c", "a"}) {
+		t.Fatalf("keys = %v, want [b c a] in eviction order", keys)
+	}
+	if key, _, _ := l.GetOldest(); key != "b" {
+		t.Fatalf("GetOldest = %s, want b", key)
+	}
+	l.Add("d", 4)
+	if l.Contains("b") || !l.Contains("c") {
+		t.Fatalf("keys after Add = %v, want b evicted", l.Keys())
+	}
+	// the new entry has no uses, so it goes next
+	if key, _, _ := l.RemoveOldest(); key != "d" {
+		t.Fatalf("RemoveOldest = %s, want d", key)
+	}
+}
+
+func TestPeekDoesNotCountUse(t *testing.T) {
+	l, err := NewLFU[int, int](2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Get(2)
+	for range 3 {
+		l.Peek(1)
+	}
+	l.Add(3, 3)
+	if l.Contains(1) || !l.Contains(2) {
+		t.Fatalf("keys = %v, want 1 evicted as Peek doesn't count", l.Keys())
+	}
+}
+
+func TestResize(t *testing.T) {
+	l, err := NewLFU[int, int](4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+		for range i {
+			l.Get(i)
+		}
+	}
+	if evicted := l.Resize(2); evicted != 2 {
+		t.Fatalf("Resize evicted %d entries, want 2", evicted)
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []int{2, 3}) {
+		t.Fatalf("keys = %v, want the most used [2 3]", keys)
+	}
+	if _, err := NewLFU[int, int](0, nil); err == nil {
+		t.Fatal("NewLFU of size 0 returned no error")
+	}
+}
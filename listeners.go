@@ -0,0 +1,79 @@
+package main
+
+import "sync"
+
+// evictListener wraps a listener so it can be found again when unregistering.
+type evictListener[K comparable, V any] struct {
+	fn func(key K, value V)
+}
+
+// AddEvictListener registers fn to be invoked on every eviction, after the callback
+// given to NewWithOnEvict and the listeners registered before it. Like the callback,
+// listeners are invoked outside the lock. The returned function unregisters fn,
+// calling it more than once has no effect.
+func (c *Cache[K, V]) AddEvictListener(fn func(key K, value V)) (unregister func()) {
+	listener := &evictListener[K, V]{fn: fn}
+	c.lock.Lock()
+	var listeners []*evictListener[K, V]
+	if current := c.evictListeners.Load(); current != nil {
+		listeners = append(listeners, *current...)
+	}
+	listeners = append(listeners, listener)
+	c.evictListeners.Store(&listeners)
+	c.lock.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { c.removeEvictListener(listener) })
+	}
+}
+
+func (c *Cache[K, V]) removeEvictListener(listener *evictListener[K, V]) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	current := c.evictListeners.Load()
+	if current == nil {
+		return
+	}
+	listeners := make([]*evictListener[K, V], 0, len(*current))
+	for _, l := range *current {
+		if l != listener {
+			listeners = append(listeners, l)
+		}
+	}
+	c.evictListeners.Store(&listeners)
+}
+
+// notifyEvicted invokes the eviction callback and the listeners, has to be called without lock.
+func (c *Cache[K, V]) notifyEvicted(key K, value V) {
+	if c.onEvict != nil {
+		c.onEvict(key, value)
+	}
+	if c.observer != nil {
+		c.observer.OnEvict(key, value)
+	}
+	if listeners := c.evictListeners.Load(); listeners != nil {
+		for _, l := range *listeners {
+			l.fn(key, value)
+		}
+	}
+}
+
+// OnCapacityEvict registers fn to be invoked for the entries evicted to make room for added ones,
+// after the eviction callback and the listeners, which are invoked for all the evictions and removals.
+// Evictions by Resize and removals like Remove or Purge don't invoke it. A nil fn unregisters the callback.
+func (c *Cache[K, V]) OnCapacityEvict(fn func(key K, value V)) {
+	if fn == nil {
+		c.onCapacityEvict.Store(nil)
+		return
+	}
+	c.onCapacityEvict.Store(&fn)
+}
+
+// notifyCapacityEvicted invokes the callbacks for an entry evicted to make room, has to be called without lock.
+func (c *Cache[K, V]) notifyCapacityEvicted(key K, value V) {
+	c.notifyEvicted(key, value)
+	if fn := c.onCapacityEvict.Load(); fn != nil {
+		(*fn)(key, value)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEvictListeners(t *testing.T) {
+	var calls []string
+	record := func(name string) func(key, value int) {
+		return func(key, value int) {
+			calls = append(calls, name)
+		}
+	}
+	c, err := NewWithOnEvict[int, int](1, record("onEvict"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unregisterMetrics := c.AddEvictListener(record("metrics"))
+	unregisterLogging := c.AddEvictListener(record("logging"))
+
+	c.Add(1, 1)
+	c.Add(2, 2)
+	if !slices.Equal(calls, []string{"onEvict", "metrics", "logging"}) {
+		t.Fatalf("calls = %v, want [onEvict metrics logging]", calls)
+	}
+
+	calls = nil
+	unregisterMetrics()
+	unregisterMetrics()
+	c.Add(3, 3)
+	if !slices.Equal(calls, []string{"onEvict", "logging"}) {
+		t.Fatalf("calls after unregistering metrics = %v, want [onEvict logging]", calls)
+	}
+
+	calls = nil
+	unregisterLogging()
+	c.AddEvictListener(func(key, value int) {
+		// listeners are invoked outside the lock
+		if c.Contains(key) {
+			t.Errorf("evicted key %d is still in the cache", key)
+		}
+		calls = append(calls, "cleanup")
+	})
+	c.Remove(3)
+	if !slices.Equal(calls, []string{"onEvict", "cleanup"}) {
+		t.Fatalf("calls on Remove = %v, want [onEvict cleanup]", calls)
+	}
+}
+
+func TestOnCapacityEvict(t *testing.T) {
+	var evicted, capacityEvicted []int
+	c, err := NewWithOnEvict[int, int](2, func(key, value int) {
+		evicted = append(evicted, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.OnCapacityEvict(func(key, value int) {
+		capacityEvicted = append(capacityEvicted, key)
+	})
+	for i := 1; i <= 4; i++ {
+		c.Add(i, i)
+	}
+	c.Remove(3)
+	c.Add(5, 5)
+	c.Resize(1)
+	c.Purge()
+
+	if !slices.Equal(evicted, []int{1, 2, 3, 4, 5}) {
+		t.Fatalf("evicted %v, want [1 2 3 4 5]", evicted)
+	}
+	// only the evictions of Add made room
+	if !slices.Equal(capacityEvicted, []int{1, 2}) {
+		t.Fatalf("evicted to make room %v, want [1 2]", capacityEvicted)
+	}
+
+	capacityEvicted = nil
+	c.OnCapacityEvict(nil)
+	c.Add(6, 6)
+	c.Add(7, 7)
+	if len(capacityEvicted) != 0 {
+		t.Fatalf("unregistered callback invoked for %v", capacityEvicted)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// MemoryMonitor evicts the oldest entries of a cache while the heap in use exceeds a threshold.
+type MemoryMonitor struct {
+	done chan struct{}
+	once sync.Once
+}
+
+// MonitorMemory starts a goroutine checking runtime.MemStats.HeapInuse every interval,
+// which evicts the oldest entries of the cache while it exceeds threshold bytes.
+// The monitor is best-effort: memory held by evicted entries is only released by the next
+// garbage collection, so each check evicts the share of the entries by which the heap
+// exceeds the threshold and leaves the rest to the following checks. Reading the memory
+// statistics briefly stops the world, so the interval should not be too short.
+func (c *Cache[K, V]) MonitorMemory(threshold uint64, interval time.Duration) (*MemoryMonitor, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid interval (%v), must be bigger than zero", interval)
+	}
+	m := &MemoryMonitor{done: make(chan struct{})}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		var stats runtime.MemStats
+		for {
+			select {
+			case <-ticker.C:
+				runtime.ReadMemStats(&stats)
+				if stats.HeapInuse <= threshold {
+					continue
+				}
+				share := float64(stats.HeapInuse-threshold) / float64(stats.HeapInuse)
+				c.removeOldestN(int(float64(c.Len())*share) + 1)
+			case <-m.done:
+				return
+			}
+		}
+	}()
+	return m, nil
+}
+
+// Stop stops the monitor, calling it more than once has no effect.
+func (m *MemoryMonitor) Stop() {
+	m.once.Do(func() {
+		close(m.done)
+	})
+}
+
+// removeOldestN evicts up to n oldest entries under one lock acquisition, returns the number of evicted entries.
+func (c *Cache[K, V]) removeOldestN(n int) (evicted int) {
+	var (
+		keys   []K
+		values []V
+	)
+	c.lock.Lock()
+	for ; evicted < n; evicted++ {
+		if _, _, ok := c.lru.RemoveOldest(); !ok {
+			break
+		}
+	}
+	c.evictions.Add(uint64(evicted))
+	if evicted > 0 {
+		keys, values = c.evictedKeys, c.evictedValues
+		c.initEvictBuffers()
+	}
+	notifyFullness := c.checkFullness()
+	c.lock.Unlock()
+	for i := 0; i < len(keys); i++ {
+		c.notifyEvicted(keys[i], values[i])
+	}
+	if notifyFullness != nil {
+		notifyFullness()
+	}
+	return evicted
+}
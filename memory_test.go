@@ -0,0 +1,68 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMonitorMemory(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		evicted []int
+	)
+	c, err := NewWithOnEvict[int, []byte](100, func(key int, value []byte) {
+		mu.Lock()
+		evicted = append(evicted, key)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		c.Add(i, make([]byte, 1024))
+	}
+
+	// any heap exceeds a threshold of a byte
+	m, err := c.MonitorMemory(1, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("monitor left %d entries", c.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	m.Stop()
+	m.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, key := range evicted {
+		if key != i {
+			t.Fatalf("evicted %d as the %d-th entry, want the oldest first", key, i)
+		}
+	}
+
+	// a stopped monitor evicts nothing, once a check in progress is done
+	time.Sleep(10 * time.Millisecond)
+	c.Add(1, nil)
+	time.Sleep(10 * time.Millisecond)
+	if !c.Contains(1) {
+		t.Fatal("stopped monitor evicted an entry")
+	}
+}
+
+func TestMonitorMemoryInvalidInterval(t *testing.T) {
+	c, err := New[int, int](1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if _, err := c.MonitorMemory(1, interval); err == nil {
+			t.Fatalf("MonitorMemory with interval %v returned no error", interval)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+// Observer receives the events of the cache, e.g. to feed a metrics system.
+// Its methods are called outside the lock, so they may block without blocking other callers.
+type Observer[K comparable, V any] interface {
+	// OnHit is called when a lookup finds the key.
+	OnHit(key K)
+	// OnMiss is called when a lookup doesn't find the key.
+	OnMiss(key K)
+	// OnEvict is called when an entry is evicted or removed, along with the eviction callback.
+	OnEvict(key K, value V)
+	// OnAdd is called when a value is added or updated.
+	OnAdd(key K)
+}
+
+// NewWithObserver creates an LRU of the given size reporting its events to observer.
+func NewWithObserver[K comparable, V any](size int, onEvict func(key K, value V), observer Observer[K, V]) (*Cache[K, V], error) {
+	c, err := NewWithOnEvict(size, onEvict)
+	if err != nil {
+		return nil, err
+	}
+	c.observer = observer
+	return c, nil
+}
+
+// observeLookup reports a lookup of the key to the observer, has to be called without lock.
+func (c *Cache[K, V]) observeLookup(key K, found bool) {
+	if c.observer == nil {
+		return
+	}
+	if found {
+		c.observer.OnHit(key)
+	} else {
+		c.observer.OnMiss(key)
+	}
+}
+
+// observeAdd reports an added value to the observer, has to be called without lock.
+func (c *Cache[K, V]) observeAdd(key K) {
+	if c.observer != nil {
+		c.observer.OnAdd(key)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+// countingObserver counts the events of each kind.
+type countingObserver struct {
+	mu                         sync.Mutex
+	hits, misses, evicts, adds int
+}
+
+func (o *countingObserver) OnHit(key string) {
+	o.mu.Lock()
+	o.hits++
+	o.mu.Unlock()
+}
+
+func (o *countingObserver) OnMiss(key string) {
+	o.mu.Lock()
+	o.misses++
+	o.mu.Unlock()
+}
+
+func (o *countingObserver) OnEvict(key string, value int) {
+	o.mu.Lock()
+	o.evicts++
+	o.mu.Unlock()
+}
+
+func (o *countingObserver) OnAdd(key string) {
+	o.mu.Lock()
+	o.adds++
+	o.mu.Unlock()
+}
+
+func TestObserver(t *testing.T) {
+	observer := &countingObserver{}
+	c, err := NewWithObserver[string, int](2, nil, observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("a", 3)
+	c.Add("c", 4) // evicts b
+	c.Get("a")
+	c.Peek("c")
+	c.Get("b")
+	c.Remove("a")
+
+	if observer.adds != 4 || observer.hits != 2 || observer.misses != 1 || observer.evicts != 2 {
+		t.Fatalf("adds %d, hits %d, misses %d, evicts %d, want 4, 2, 1, 2",
+			observer.adds, observer.hits, observer.misses, observer.evicts)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+// Item is a key with its value, as returned by Page.
+type Item[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// Cursor is a position in the cache for Page. The zero Cursor starts at the oldest entry.
+type Cursor[K comparable] struct {
+	// last key returned and the position after it
+	key     K
+	next    int
+	started bool
+	done    bool
+}
+
+// Done reports whether the page which returned the cursor reached the newest entry.
+func (c Cursor[K]) Done() bool {
+	return c.done
+}
+
+// Page returns up to limit entries from oldest to newest, starting after the last key returned
+// with cursor, and the cursor to pass to get the next page. If the last key was removed or moved
+// by a change of its recency of usage, the page starts at the position after it instead,
+// so concurrent changes can make pages skip or repeat entries. Finding the position walks the list,
+// so each call takes O(n) time.
+func (c *Cache[K, V]) Page(cursor Cursor[K], limit int) (items []Item[K, V], next Cursor[K]) {
+	if cursor.done || limit <= 0 {
+		return nil, cursor
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	start := cursor.next
+	if cursor.started {
+		if rank, ok := c.lru.EvictionRank(cursor.key); ok {
+			start = rank + 1
+		}
+	}
+	i := 0
+	c.lru.Range(func(key K, value V) bool {
+		if i >= start {
+			items = append(items, Item[K, V]{Key: key, Value: value})
+		}
+		i++
+		return len(items) < limit
+	})
+	next = Cursor[K]{next: start + len(items), started: true}
+	if len(items) > 0 {
+		next.key = items[len(items)-1].Key
+	} else {
+		next.started = false
+	}
+	next.done = next.next >= c.lru.Len()
+	return items, next
+}
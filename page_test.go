@@ -0,0 +1,54 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPage(t *testing.T) {
+	c, _ := New[int, int](16)
+	for i := range 10 {
+		c.Add(i, i*i)
+	}
+	c.Get(0)
+
+	var keys []int
+	var cursor Cursor[int]
+	for pages := 0; !cursor.Done(); pages++ {
+		if pages == 4 {
+			t.Fatal("paging didn't end after 4 pages of 3")
+		}
+		var items []Item[int, int]
+		items, cursor = c.Page(cursor, 3)
+		for _, item := range items {
+			if item.Value != item.Key*item.Key {
+				t.Fatalf("item %+v has the value of another key", item)
+			}
+			keys = append(keys, item.Key)
+		}
+	}
+	// every key once, in recency order
+	if !slices.Equal(keys, c.Keys()) {
+		t.Fatalf("paged keys %v, want %v", keys, c.Keys())
+	}
+	if items, _ := c.Page(cursor, 3); items != nil {
+		t.Fatalf("page after the end = %v, want none", items)
+	}
+}
+
+func TestPageRemovedCursorKey(t *testing.T) {
+	c, _ := New[int, int](16)
+	for i := range 6 {
+		c.Add(i, i)
+	}
+	items, cursor := c.Page(Cursor[int]{}, 2)
+	if len(items) != 2 || items[1].Key != 1 {
+		t.Fatalf("first page = %v, want keys 0 and 1", items)
+	}
+	// the page resumes at the position after the removed key, skipping 2 which moved into it
+	c.Remove(1)
+	items, _ = c.Page(cursor, 2)
+	if len(items) != 2 || items[0].Key != 3 || items[1].Key != 4 {
+		t.Fatalf("page after removing the cursor key = %v, want keys 3 and 4", items)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"lru/basic_lru"
+)
+
+// DumpKeys writes the keys of the cache, from oldest to newest, to w with encoding/gob.
+// The values are left out to keep the dump compact. Read the keys back with LoadKeys.
+func (c *Cache[K, V]) DumpKeys(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(c.Keys())
+}
+
+// LoadKeys reads the keys written by DumpKeys from r, in the same order.
+func LoadKeys[K comparable](r io.Reader) ([]K, error) {
+	var keys []K
+	if err := gob.NewDecoder(r).Decode(&keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Save writes the entries of the cache with their metadata, from oldest to newest, to w with encoding/gob.
+// Restore them with Load. The keys and values have to be encodable by encoding/gob.
+func (c *Cache[K, V]) Save(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(c.Export())
+}
+
+// Load reads the entries written by Save from r and adds them from oldest to newest,
+// restoring their recency order. If they don't fit, the oldest ones are evicted.
+func (c *Cache[K, V]) Load(r io.Reader) error {
+	var entries []basic_lru.ExportedEntry[K, V]
+	if err := gob.NewDecoder(r).Decode(&entries); err != nil {
+		return err
+	}
+	c.Import(entries)
+	return nil
+}
+
+// jsonEntry is an entry of the cache as encoded in JSON.
+type jsonEntry[K comparable, V any] struct {
+	Key   K `json:"key"`
+	Value V `json:"value"`
+}
+
+// MarshalJSON encodes the entries of the cache as an array of {"key": ..., "value": ...} objects,
+// from oldest to newest. The metadata of the entries is left out.
+func (c *Cache[K, V]) MarshalJSON() ([]byte, error) {
+	c.lock.RLock()
+	entries := make([]jsonEntry[K, V], 0, c.lru.Len())
+	c.lru.Range(func(key K, value V) bool {
+		entries = append(entries, jsonEntry[K, V]{Key: key, Value: value})
+		return true
+	})
+	c.lock.RUnlock()
+	return json.Marshal(entries)
+}
+
+// UnmarshalJSON adds the entries encoded by MarshalJSON from oldest to newest, restoring their
+// recency order. If they don't fit, the oldest ones are evicted. A zero Cache gets the size
+// of the encoded entries.
+func (c *Cache[K, V]) UnmarshalJSON(data []byte) error {
+	var entries []jsonEntry[K, V]
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	c.lock.Lock()
+	if c.lru == nil {
+		c.initEvictBuffers()
+		c.lru, _ = basic_lru.NewLRU(max(len(entries), 1), c.onEvictCB, c.writeCallbacks()...)
+	}
+	c.lock.Unlock()
+	for _, entry := range entries {
+		c.Add(entry.Key, entry.Value)
+	}
+	return nil
+}
+
+// LoadNDJSON reads newline-delimited JSON objects like {"key": ..., "value": ...} from r
+// one line at a time and adds them in order, so the last lines are the newest and,
+// if there are more than the capacity, the first ones are evicted. Blank lines are skipped.
+// It stops at the first line which can't be decoded, returning the error with its line number,
+// the entries of the preceding lines stay added. loaded is the number of added entries.
+func (c *Cache[K, V]) LoadNDJSON(r io.Reader) (loaded int, err error) {
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return loaded, fmt.Errorf("line %d: %w", line, err)
+		}
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			var entry jsonEntry[K, V]
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return loaded, fmt.Errorf("line %d: %w", line, err)
+			}
+			c.Add(entry.Key, entry.Value)
+			loaded++
+		}
+		if err != nil {
+			return loaded, nil
+		}
+	}
+}
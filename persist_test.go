@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDumpLoadKeys(t *testing.T) {
+	c, err := New[string, []byte](4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add("a", []byte("large value"))
+	c.Add("b", nil)
+	c.Add("c", nil)
+	c.Get("a")
+
+	var buf bytes.Buffer
+	if err := c.DumpKeys(&buf); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := LoadKeys[string](&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(keys, []string{"b", "c", "a"}) {
+		t.Fatalf("loaded keys %v, want [b c a]", keys)
+	}
+
+	if _, err := LoadKeys[string](bytes.NewReader([]byte("garbage"))); err == nil {
+		t.Fatal("LoadKeys of invalid data returned no error")
+	}
+}
+
+func TestLoadNDJSON(t *testing.T) {
+	c, err := New[string, int](2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := `{"key": "a", "value": 1}
+{"key": "b", "value": 2}
+
+{"key": "c", "value": 3}
+{"key": "d", "value":
+{"key": "e", "value": 5}
+`
+	loaded, err := c.LoadNDJSON(strings.NewReader(input))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 5:") {
+		t.Fatalf("error = %v, want one of line 5", err)
+	}
+	// the lines before the malformed one stay loaded, the first ones evicted
+	if loaded != 3 {
+		t.Fatalf("loaded = %d, want 3", loaded)
+	}
+	if keys := c.Keys(); !slices.Equal(keys, []string{"b", "c"}) {
+		t.Fatalf("keys = %v, want [b c]", keys)
+	}
+
+	// the last line needs no newline
+	loaded, err = c.LoadNDJSON(strings.NewReader(`{"key": "e", "value": 5}`))
+	if err != nil || loaded != 1 {
+		t.Fatalf("LoadNDJSON() = %d, %v, want 1, nil", loaded, err)
+	}
+	if value, _ := c.Peek("e"); value != 5 {
+		t.Fatalf("e = %d, want 5", value)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	src, _ := New[string, int](4)
+	for i, key := range []string{"a", "b", "c", "d"} {
+		src.Add(key, i)
+	}
+	src.Get("a")
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	saved := buf.Bytes()
+
+	dst, _ := New[string, int](4)
+	if err := dst.Load(bytes.NewReader(saved)); err != nil {
+		t.Fatal(err)
+	}
+	if keys := dst.Keys(); !slices.Equal(keys, src.Keys()) {
+		t.Fatalf("keys = %v, want %v", keys, src.Keys())
+	}
+	if values := dst.Values(); !slices.Equal(values, src.Values()) {
+		t.Fatalf("values = %v, want %v", values, src.Values())
+	}
+
+	// the oldest entries are evicted from a smaller cache
+	small, _ := New[string, int](2)
+	if err := small.Load(bytes.NewReader(saved)); err != nil {
+		t.Fatal(err)
+	}
+	if keys := small.Keys(); !slices.Equal(keys, []string{"d", "a"}) {
+		t.Fatalf("keys = %v, want [d a]", keys)
+	}
+
+	if err := dst.Load(strings.NewReader("garbage")); err == nil {
+		t.Fatal("Load of invalid data returned no error")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	src, _ := New[string, int](4)
+	src.Add("a", 1)
+	src.Add("b", 2)
+	src.Add("c", 3)
+	src.Get("a")
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"key":"b","value":2},{"key":"c","value":3},{"key":"a","value":1}]`
+	if string(data) != want {
+		t.Fatalf("json.Marshal() = %s, want %s", data, want)
+	}
+
+	// a zero cache gets the size of the entries
+	var dst Cache[string, int]
+	if err := json.Unmarshal(data, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if keys := dst.Keys(); !slices.Equal(keys, []string{"b", "c", "a"}) || dst.Cap() != 3 {
+		t.Fatalf("keys = %v with capacity %d, want [b c a] with 3", keys, dst.Cap())
+	}
+	if values := dst.Values(); !slices.Equal(values, []int{2, 3, 1}) {
+		t.Fatalf("values = %v, want [2 3 1]", values)
+	}
+
+	small, _ := New[string, int](2)
+	if err := json.Unmarshal(data, small); err != nil {
+		t.Fatal(err)
+	}
+	if keys := small.Keys(); !slices.Equal(keys, []string{"c", "a"}) {
+		t.Fatalf("keys = %v, want [c a]", keys)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+// PublishSnapshot stores a copy of the current cache contents which SnapshotGet reads
+// without taking the lock. The copy is not updated by later changes of the cache,
+// so reads are as stale as the time since the last call.
+func (c *Cache[K, V]) PublishSnapshot() {
+	snapshot := c.Entries()
+	c.published.Store(&snapshot)
+}
+
+// SnapshotGet returns key's value from the snapshot stored by the last PublishSnapshot
+// without taking the lock or updating the recency of usage of the key.
+// ok is false if the key was missing then or no snapshot was published.
+func (c *Cache[K, V]) SnapshotGet(key K) (value V, ok bool) {
+	snapshot := c.published.Load()
+	if snapshot == nil {
+		return value, false
+	}
+	value, ok = (*snapshot)[key]
+	return value, ok
+}
@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestSnapshotGet(t *testing.T) {
+	c, err := New[string, int](4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add("a", 1)
+	if _, ok := c.SnapshotGet("a"); ok {
+		t.Fatal("SnapshotGet before PublishSnapshot = true")
+	}
+
+	c.PublishSnapshot()
+	if value, ok := c.SnapshotGet("a"); !ok || value != 1 {
+		t.Fatalf("SnapshotGet = %d, %v, want 1, true", value, ok)
+	}
+	// changes are not visible until the next publish
+	c.Add("a", 2)
+	c.Add("b", 3)
+	if value, _ := c.SnapshotGet("a"); value != 1 {
+		t.Fatalf("SnapshotGet = %d, want the published 1", value)
+	}
+	if _, ok := c.SnapshotGet("b"); ok {
+		t.Fatal("SnapshotGet sees a key added after the publish")
+	}
+
+	c.PublishSnapshot()
+	c.Remove("a")
+	if value, ok := c.SnapshotGet("a"); !ok || value != 2 {
+		t.Fatalf("SnapshotGet = %d, %v, want the republished 2, true", value, ok)
+	}
+	if value, ok := c.SnapshotGet("b"); !ok || value != 3 {
+		t.Fatalf("SnapshotGet = %d, %v, want 3, true", value, ok)
+	}
+	// reads don't update the recency of usage
+	c.Add("c", 4)
+	if keys := c.Keys(); keys[0] != "b" {
+		t.Fatalf("keys = %v, want b the oldest", keys)
+	}
+}
+
+func BenchmarkParallelGet(b *testing.B) {
+	const size = 1024
+	c, err := New[int, int](size)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := range size {
+		c.Add(i, i)
+	}
+	c.PublishSnapshot()
+
+	b.Run("Get", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				c.Get(i % size)
+			}
+		})
+	})
+	b.Run("SnapshotGet", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				c.SnapshotGet(i % size)
+			}
+		})
+	})
+}
@@ -0,0 +1,210 @@
+package main
+
+import "context"
+
+// Reservation coordinates loading of a missing key between the callers of GetOrReserve.
+// The owner of the reservation is responsible for loading the value and has to
+// call either Fulfill or Fail, the other callers wait for the outcome.
+type Reservation[K comparable, V any] struct {
+	cache *Cache[K, V]
+	key   K
+	owner bool
+	load  *load[V]
+}
+
+// load is the state of a key being loaded, shared by the owner and the waiters.
+type load[V any] struct {
+	done  chan struct{}
+	value V
+	ok    bool
+	// error of a failed computation shared with the waiters of GetOrComputeContext
+	err error
+}
+
+// GetOrReserve returns key's value from the cache and updates the recency of usage of the key.
+// If the key is missing, a reservation is returned instead. The first caller owns it and
+// has to load the value, the callers arriving before it is loaded get a reservation to wait on.
+func (c *Cache[K, V]) GetOrReserve(key K) (value V, found bool, reservation *Reservation[K, V]) {
+	c.lock.Lock()
+	value, found = c.lru.Get(key)
+	c.countLookup(found)
+	if found {
+		c.lock.Unlock()
+		c.observeLookup(key, true)
+		return value, true, nil
+	}
+	if l, ok := c.loads[key]; ok {
+		c.lock.Unlock()
+		c.observeLookup(key, false)
+		return value, false, &Reservation[K, V]{cache: c, key: key, load: l}
+	}
+	if c.loads == nil {
+		c.loads = make(map[K]*load[V])
+	}
+	l := &load[V]{done: make(chan struct{})}
+	c.loads[key] = l
+	c.lock.Unlock()
+	c.observeLookup(key, false)
+	return value, false, &Reservation[K, V]{cache: c, key: key, owner: true, load: l}
+}
+
+// Owner reports whether the holder of the reservation has to load the value.
+func (r *Reservation[K, V]) Owner() bool {
+	return r.owner
+}
+
+// Fulfill adds the loaded value to the cache and releases the waiters.
+// It does nothing unless called by the owner for the first time.
+func (r *Reservation[K, V]) Fulfill(value V) {
+	var (
+		k K
+		v V
+	)
+	if !r.owner {
+		return
+	}
+	c := r.cache
+	c.lock.Lock()
+	if c.loads[r.key] != r.load {
+		c.lock.Unlock()
+		return
+	}
+	delete(c.loads, r.key)
+	evicted := c.lru.Add(r.key, value)
+	if evicted {
+		c.evictions.Add(1)
+	}
+	if evicted {
+		k, v = c.evictedKeys[0], c.evictedValues[0]
+		c.evictedKeys, c.evictedValues = c.evictedKeys[:0], c.evictedValues[:0]
+	}
+	writes := c.takeWrites()
+	notifyFullness := c.checkFullness()
+	c.lock.Unlock()
+	c.observeAdd(r.key)
+	r.load.value, r.load.ok = value, true
+	close(r.load.done)
+	c.notifyWrites(writes)
+	if evicted {
+		c.notifyCapacityEvicted(k, v)
+	}
+	if notifyFullness != nil {
+		notifyFullness()
+	}
+}
+
+// Fail releases the waiters without adding a value, the next GetOrReserve makes a new reservation.
+// It does nothing unless called by the owner for the first time.
+func (r *Reservation[K, V]) Fail() {
+	r.fail(nil)
+}
+
+// fail releases the waiters with the error, a nil error makes them try again.
+func (r *Reservation[K, V]) fail(err error) {
+	if !r.owner {
+		return
+	}
+	c := r.cache
+	c.lock.Lock()
+	if c.loads[r.key] != r.load {
+		c.lock.Unlock()
+		return
+	}
+	delete(c.loads, r.key)
+	c.lock.Unlock()
+	r.load.err = err
+	close(r.load.done)
+}
+
+// Wait blocks until the owner fulfills or fails the reservation and returns the loaded value.
+// ok is false if loading failed. The owner must not call Wait before Fulfill or Fail.
+func (r *Reservation[K, V]) Wait() (value V, ok bool) {
+	<-r.load.done
+	return r.load.value, r.load.ok
+}
+
+// GetOrCompute returns key's value from the cache and updates the recency of usage of the key.
+// If the key is missing, the value returned by fn is added and returned. fn is called without
+// the lock held, and only by one of the concurrent callers for the same key, the others wait
+// for its value. loaded is false if the value was computed by this call.
+// If fn panics, the waiting callers try again.
+func (c *Cache[K, V]) GetOrCompute(key K, fn func() V) (value V, loaded bool) {
+	for {
+		value, found, reservation := c.GetOrReserve(key)
+		if found {
+			return value, true
+		}
+		if reservation.Owner() {
+			return reservation.compute(fn), false
+		}
+		if value, ok := reservation.Wait(); ok {
+			return value, true
+		}
+	}
+}
+
+// compute fulfills the reservation with the value returned by fn, failing it if fn panics.
+func (r *Reservation[K, V]) compute(fn func() V) V {
+	fulfilled := false
+	defer func() {
+		if !fulfilled {
+			r.Fail()
+		}
+	}()
+	value := fn()
+	r.Fulfill(value)
+	fulfilled = true
+	return value
+}
+
+// GetOrComputeContext is GetOrCompute for a fn which takes a context and can fail.
+// An error returned by fn is returned to the waiting callers too and nothing is added.
+// If ctx is done before fn returns, ctx.Err() is returned and nothing is added,
+// the waiting callers try again with their own contexts. A waiting caller whose ctx
+// is done returns ctx.Err() without waiting further.
+func (c *Cache[K, V]) GetOrComputeContext(ctx context.Context, key K, fn func(ctx context.Context) (V, error)) (V, error) {
+	for {
+		value, found, reservation := c.GetOrReserve(key)
+		if found {
+			return value, nil
+		}
+		if reservation.Owner() {
+			return reservation.computeContext(ctx, fn)
+		}
+		select {
+		case <-reservation.load.done:
+			if reservation.load.ok {
+				return reservation.load.value, nil
+			}
+			if reservation.load.err != nil {
+				return value, reservation.load.err
+			}
+		case <-ctx.Done():
+			return value, ctx.Err()
+		}
+	}
+}
+
+// computeContext fulfills the reservation with the value returned by fn or fails it with the error,
+// failing it without an error if ctx is done or fn panics.
+func (r *Reservation[K, V]) computeContext(ctx context.Context, fn func(ctx context.Context) (V, error)) (value V, err error) {
+	failed := true
+	defer func() {
+		if failed {
+			r.Fail()
+		}
+	}()
+	value, err = fn(ctx)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		var zero V
+		return zero, ctxErr
+	}
+	if err != nil {
+		failed = false
+		r.fail(err)
+		return value, err
+	}
+	r.Fulfill(value)
+	failed = false
+	return value, nil
+}
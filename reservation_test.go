@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetOrReserveConcurrent(t *testing.T) {
+	c, err := New[string, int](8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const callers = 16
+	var (
+		owners atomic.Int32
+		wg     sync.WaitGroup
+		start  = make(chan struct{})
+		values = make(chan int, callers)
+	)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			value, found, r := c.GetOrReserve("k")
+			switch {
+			case found:
+			case r.Owner():
+				owners.Add(1)
+				value = 42
+				r.Fulfill(value)
+			default:
+				var ok bool
+				if value, ok = r.Wait(); !ok {
+					t.Error("Wait of a fulfilled reservation returned ok = false")
+				}
+			}
+			values <- value
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(values)
+
+	if n := owners.Load(); n != 1 {
+		t.Fatalf("%d callers owned the reservation, want 1", n)
+	}
+	for value := range values {
+		if value != 42 {
+			t.Fatalf("caller got %d, want 42", value)
+		}
+	}
+	if value, ok := c.Peek("k"); !ok || value != 42 {
+		t.Fatalf("Peek after Fulfill = %d, %v, want 42, true", value, ok)
+	}
+}
+
+func TestReservationFailReleasesWaiters(t *testing.T) {
+	c, err := New[string, int](8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, owner := c.GetOrReserve("k")
+	if !owner.Owner() {
+		t.Fatal("first GetOrReserve did not own the reservation")
+	}
+
+	const waiters = 8
+	var wg sync.WaitGroup
+	failed := make(chan bool, waiters)
+	for i := 0; i < waiters; i++ {
+		_, found, r := c.GetOrReserve("k")
+		if found || r.Owner() {
+			t.Fatalf("waiter got found = %v, owner = %v", found, r.Owner())
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, ok := r.Wait()
+			failed <- !ok
+		}()
+	}
+	owner.Fail()
+	wg.Wait()
+	close(failed)
+	for f := range failed {
+		if !f {
+			t.Fatal("Wait after Fail returned ok = true")
+		}
+	}
+	if c.Contains("k") {
+		t.Fatal("Fail added the key")
+	}
+	// a later Fulfill of the failed reservation does nothing
+	owner.Fulfill(1)
+	if c.Contains("k") {
+		t.Fatal("Fulfill after Fail added the key")
+	}
+	// the next caller owns a new reservation
+	if _, _, r := c.GetOrReserve("k"); !r.Owner() {
+		t.Fatal("GetOrReserve after Fail did not make a new reservation")
+	}
+}
+
+func TestGetOrComputeOnce(t *testing.T) {
+	c, err := New[string, int](8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const callers = 32
+	var (
+		calls  atomic.Int32
+		loaded atomic.Int32
+		wg     sync.WaitGroup
+		start  = make(chan struct{})
+		inFn   = make(chan struct{})
+		finish = make(chan struct{})
+	)
+	fn := func() int {
+		if calls.Add(1) == 1 {
+			close(inFn)
+		}
+		// hold the computation until the other callers are waiting for it
+		<-finish
+		return 42
+	}
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			value, l := c.GetOrCompute("k", fn)
+			if value != 42 {
+				t.Errorf("GetOrCompute = %d, want 42", value)
+			}
+			if l {
+				loaded.Add(1)
+			}
+		}()
+	}
+	close(start)
+	<-inFn
+	close(finish)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("fn ran %d times, want 1", n)
+	}
+	if n := loaded.Load(); n != callers-1 {
+		t.Fatalf("%d callers loaded the value, want %d", n, callers-1)
+	}
+	// a present key doesn't call fn
+	if value, l := c.GetOrCompute("k", func() int { panic("fn called for a present key") }); value != 42 || !l {
+		t.Fatalf("GetOrCompute of a present key = %d, %v, want 42, true", value, l)
+	}
+}
+
+func TestGetOrComputePanic(t *testing.T) {
+	c, err := New[string, int](8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("the panic of fn was not propagated")
+			}
+		}()
+		c.GetOrCompute("k", func() int { panic("failed") })
+	}()
+	// the failed reservation is released, so the next caller computes the value
+	if value, l := c.GetOrCompute("k", func() int { return 1 }); value != 1 || l {
+		t.Fatalf("GetOrCompute after a panic = %d, %v, want 1, false", value, l)
+	}
+}
+
+func TestGetOrComputeContextShared(t *testing.T) {
+	c, err := New[string, int](8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const callers = 16
+	var (
+		calls  atomic.Int32
+		wg     sync.WaitGroup
+		inFn   = make(chan struct{})
+		finish = make(chan struct{})
+	)
+	fn := func(ctx context.Context) (int, error) {
+		if calls.Add(1) == 1 {
+			close(inFn)
+		}
+		<-finish
+		return 42, nil
+	}
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, err := c.GetOrComputeContext(context.Background(), "k", fn); value != 42 || err != nil {
+				t.Errorf("GetOrComputeContext = %d, %v, want 42, nil", value, err)
+			}
+		}()
+	}
+	<-inFn
+	close(finish)
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("fn ran %d times, want 1", n)
+	}
+
+	// an error of fn is shared and nothing is added
+	failure := errors.New("failure")
+	if _, err := c.GetOrComputeContext(context.Background(), "bad", func(ctx context.Context) (int, error) {
+		return 0, failure
+	}); !errors.Is(err, failure) || c.Contains("bad") {
+		t.Fatalf("error = %v with bad present %v, want %v and false", err, c.Contains("bad"), failure)
+	}
+}
+
+func TestGetOrComputeContextCancel(t *testing.T) {
+	c, err := New[string, int](8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	inFn := make(chan struct{})
+	ownerErr := make(chan error)
+	go func() {
+		_, err := c.GetOrComputeContext(ctx, "k", func(ctx context.Context) (int, error) {
+			close(inFn)
+			<-ctx.Done()
+			return 0, ctx.Err()
+		})
+		ownerErr <- err
+	}()
+	<-inFn
+
+	// a waiter whose context is done gives up, the computation goes on
+	waitCtx, waitCancel := context.WithCancel(context.Background())
+	waitCancel()
+	if _, err := c.GetOrComputeContext(waitCtx, "k", func(ctx context.Context) (int, error) {
+		t.Error("fn of a waiter called while the owner computes")
+		return 0, nil
+	}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("error of a canceled waiter = %v, want %v", err, context.Canceled)
+	}
+
+	// a waiter with a live context computes the value itself once the owner is canceled
+	waiterValue := make(chan int)
+	go func() {
+		value, err := c.GetOrComputeContext(context.Background(), "k", func(ctx context.Context) (int, error) {
+			return 2, nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		waiterValue <- value
+	}()
+	cancel()
+	if err := <-ownerErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("error of the canceled owner = %v, want %v", err, context.Canceled)
+	}
+	if value := <-waiterValue; value != 2 {
+		t.Fatalf("waiter got %d, want 2", value)
+	}
+	if value, _ := c.Peek("k"); value != 2 {
+		t.Fatalf("k = %d, want 2 computed by the waiter", value)
+	}
+}
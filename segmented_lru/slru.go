@@ -0,0 +1,271 @@
+package segmented_lru
+
+import (
+	"fmt"
+	"lru/basic_lru"
+	"lru/internal"
+	"time"
+)
+
+// SLRU has to be a drop-in replacement for basic_lru.LRU.
+var _ basic_lru.LRUCache[int, int] = (*SLRU[int, int])(nil)
+
+// EvictCallback is used to get a callback when a cache entry is evicted
+type EvictCallback[K comparable, V any] func(key K, value V)
+
+// segments of the cache, stored in the Bucket field of the entries
+const (
+	probationary uint8 = iota
+	protected
+)
+
+// SLRU implements a non-thread safe fixed size segmented LRU cache.
+// New entries enter the probationary segment and move to the protected segment when they are hit again,
+// so keys read only once, e.g. by a scan, are evicted before the keys which are read repeatedly.
+// When the protected segment is full, its oldest entry goes back to the front of the probationary one.
+// Entries are evicted from the probationary segment, from the protected one only if the former is empty.
+type SLRU[K comparable, V any] struct {
+	size           int
+	protectedRatio float64
+	protectedSize  int
+	probationList  *internal.LRUList[K, V]
+	protectedList  *internal.LRUList[K, V]
+	entries        map[K]*internal.Entry[K, V]
+	onEvict        EvictCallback[K, V]
+}
+
+// NewSLRU constructs an SLRU of the given total size, of which protectedRatio is the share
+// of the protected segment. At least one entry is left to the probationary segment.
+func NewSLRU[K comparable, V any](size int, protectedRatio float64, onEvict EvictCallback[K, V]) (*SLRU[K, V], error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid cache size (%d), must be bigger than zero", size)
+	}
+	if protectedRatio < 0 || protectedRatio >= 1 {
+		return nil, fmt.Errorf("invalid protected ratio (%v), must be between 0 and 1", protectedRatio)
+	}
+
+	l := &SLRU[K, V]{
+		protectedRatio: protectedRatio,
+		probationList:  internal.NewList[K, V](),
+		protectedList:  internal.NewList[K, V](),
+		entries:        make(map[K]*internal.Entry[K, V]),
+		onEvict:        onEvict,
+	}
+	l.setSize(size)
+
+	return l, nil
+}
+
+// Add adds an entry to the cache, returns true if an eviction occurred and
+// updates the recency of usage of the key. Adding a present key counts as a hit.
+func (l *SLRU[K, V]) Add(key K, value V) (evicted bool) {
+	// check for existing entry
+	if entry, ok := l.entries[key]; ok {
+		entry.Value = value
+		l.hit(entry)
+		return false
+	}
+
+	// add new entry to the probationary segment
+	entry := l.probationList.PushToFront(key, value)
+	entry.CreatedAt = time.Now()
+	entry.LastAccess = entry.CreatedAt
+	entry.Bucket = probationary
+	l.entries[key] = entry
+
+	evict := len(l.entries) > l.size
+	if evict {
+		l.removeOldest()
+	}
+	return evict
+}
+
+// Get returns key's value from the cache and updates the recency of usage of the key.
+// ok specifies if the key was found or not.
+func (l *SLRU[K, V]) Get(key K) (value V, ok bool) {
+	if entry, ok := l.entries[key]; ok {
+		l.hit(entry)
+		return entry.Value, true
+	}
+	return value, false
+}
+
+// Contains checks if a key exists in the cache without updating the recency of usage.
+func (l *SLRU[K, V]) Contains(key K) (ok bool) {
+	_, ok = l.entries[key]
+	return ok
+}
+
+// Peek returns key's value without updating the recency of usage of the key.
+// ok specifies if the key was found or not.
+func (l *SLRU[K, V]) Peek(key K) (value V, ok bool) {
+	if entry, ok := l.entries[key]; ok {
+		return entry.Value, true
+	}
+	return value, false
+}
+
+// Remove removes an entry from the cache with the key specified.
+// ok specifies if the key was found or not.
+func (l *SLRU[K, V]) Remove(key K) (ok bool) {
+	if entry, ok := l.entries[key]; ok {
+		l.removeEntry(entry)
+		return true
+	}
+	return false
+}
+
+// RemoveOldest removes the entry evicted next from the cache.
+func (l *SLRU[K, V]) RemoveOldest() (key K, value V, ok bool) {
+	if entry := l.oldest(); entry != nil {
+		key, value = entry.Key, entry.Value
+		l.removeEntry(entry)
+		return key, value, true
+	}
+	return key, value, false
+}
+
+// GetOldest returns the entry evicted next from the cache.
+func (l *SLRU[K, V]) GetOldest() (key K, value V, ok bool) {
+	if entry := l.oldest(); entry != nil {
+		return entry.Key, entry.Value, true
+	}
+	return key, value, false
+}
+
+// Keys returns a slice of the keys in the cache, from oldest to newest,
+// the probationary segment before the protected one.
+func (l *SLRU[K, V]) Keys() []K {
+	keys := make([]K, 0, len(l.entries))
+	l.walk(func(entry *internal.Entry[K, V]) {
+		keys = append(keys, entry.Key)
+	})
+	return keys
+}
+
+// Values returns a slice of the values in the cache, from oldest to newest,
+// the probationary segment before the protected one.
+func (l *SLRU[K, V]) Values() []V {
+	values := make([]V, 0, len(l.entries))
+	l.walk(func(entry *internal.Entry[K, V]) {
+		values = append(values, entry.Value)
+	})
+	return values
+}
+
+// Len returns the number of entries in the cache.
+func (l *SLRU[K, V]) Len() int {
+	return len(l.entries)
+}
+
+// Cap returns the capacity of the cache.
+func (l *SLRU[K, V]) Cap() int {
+	return l.size
+}
+
+// ProtectedLen returns the number of entries in the protected segment.
+func (l *SLRU[K, V]) ProtectedLen() int {
+	return l.protectedList.Len()
+}
+
+// Purge clears all the cache entries, invoking the eviction callback from oldest to newest.
+func (l *SLRU[K, V]) Purge() {
+	if l.onEvict != nil {
+		l.walk(func(entry *internal.Entry[K, V]) {
+			l.onEvict(entry.Key, entry.Value)
+		})
+	}
+	clear(l.entries)
+	l.probationList.Init()
+	l.protectedList.Init()
+}
+
+// Resize changes the cache size, keeping the protected ratio, returning number of evicted entries.
+func (l *SLRU[K, V]) Resize(size int) (evicted int) {
+	diff := l.Len() - size
+	if diff < 0 {
+		diff = 0
+	}
+	for i := 0; i < diff; i++ {
+		l.removeOldest()
+	}
+	l.setSize(size)
+	for l.protectedList.Len() > l.protectedSize {
+		l.demote()
+	}
+	return diff
+}
+
+// setSize sets the total size and the size of the protected segment.
+func (l *SLRU[K, V]) setSize(size int) {
+	l.size = size
+	l.protectedSize = int(float64(size) * l.protectedRatio)
+	if l.protectedSize > size-1 {
+		l.protectedSize = max(size-1, 0)
+	}
+}
+
+// hit updates the recency of usage of the entry, promoting it to the protected segment.
+func (l *SLRU[K, V]) hit(entry *internal.Entry[K, V]) {
+	entry.Accessed(time.Now())
+	if entry.Bucket == protected {
+		l.protectedList.MoveToFront(entry)
+		return
+	}
+	if l.protectedSize == 0 {
+		l.probationList.MoveToFront(entry)
+		return
+	}
+	l.probationList.Remove(entry)
+	entry.Bucket = protected
+	l.protectedList.PushEntryToFront(entry)
+	if l.protectedList.Len() > l.protectedSize {
+		l.demote()
+	}
+}
+
+// demote moves the oldest entry of the protected segment to the front of the probationary one.
+func (l *SLRU[K, V]) demote() {
+	entry := l.protectedList.Back()
+	l.protectedList.Remove(entry)
+	entry.Bucket = probationary
+	l.probationList.PushEntryToFront(entry)
+}
+
+// oldest returns the entry evicted next.
+func (l *SLRU[K, V]) oldest() *internal.Entry[K, V] {
+	if entry := l.probationList.Back(); entry != nil {
+		return entry
+	}
+	return l.protectedList.Back()
+}
+
+// walk calls fn for the entries from oldest to newest, the probationary segment first.
+func (l *SLRU[K, V]) walk(fn func(entry *internal.Entry[K, V])) {
+	for entry := l.probationList.Back(); entry != nil; entry = entry.PrevEntry() {
+		fn(entry)
+	}
+	for entry := l.protectedList.Back(); entry != nil; entry = entry.PrevEntry() {
+		fn(entry)
+	}
+}
+
+// removeOldest removes the entry evicted next from the cache.
+func (l *SLRU[K, V]) removeOldest() {
+	if entry := l.oldest(); entry != nil {
+		l.removeEntry(entry)
+	}
+}
+
+// removeEntry is used to remove a given entry from the cache
+func (l *SLRU[K, V]) removeEntry(entry *internal.Entry[K, V]) {
+	if entry.Bucket == protected {
+		l.protectedList.Remove(entry)
+	} else {
+		l.probationList.Remove(entry)
+	}
+	delete(l.entries, entry.Key)
+	if l.onEvict != nil {
+		l.onEvict(entry.Key, entry.Value)
+	}
+}
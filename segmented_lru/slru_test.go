@@ -0,0 +1,77 @@
+package segmented_lru
+
+import (
+	"lru/basic_lru"
+	"slices"
+	"testing"
+)
+
+func TestScanResistance(t *testing.T) {
+	const size = 10
+	slru, err := NewSLRU[int, int](size, 0.5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lru, err := basic_lru.NewLRU[int, int](size, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []basic_lru.LRUCache[int, int]{slru, lru} {
+		c.Add(-1, -1)
+		c.Get(-1)
+		c.Get(-1)
+		// a long scan of keys read once
+		for i := 0; i < 100; i++ {
+			c.Add(i, i)
+		}
+	}
+	if !slru.Contains(-1) {
+		t.Fatalf("hot key was evicted from the SLRU by the scan, keys %v", slru.Keys())
+	}
+	if lru.Contains(-1) {
+		t.Fatal("hot key survived the scan in a plain LRU")
+	}
+	if slru.ProtectedLen() != 1 {
+		t.Fatalf("ProtectedLen = %d, want 1", slru.ProtectedLen())
+	}
+}
+
+func TestDemotion(t *testing.T) {
+	var evicted []string
+	l, err := NewSLRU[string, int](4, 0.5, func(key string, value int) {
+		evicted = append(evicted, key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		l.Add(key, 0)
+		l.Get(key)
+	}
+	// the protected segment holds 2, so a went back to probation
+	if l.ProtectedLen() != 2 {
+		t.Fatalf("ProtectedLen = %d, want 2", l.ProtectedLen())
+	}
+	if key, _, _ := l.GetOldest(); key != "a" {
+		t.Fatalf("GetOldest = %s, want the demoted a", key)
+	}
+	l.Add("d", 0)
+	l.Add("e", 0)
+	if !slices.Equal(evicted, []string{"a"}) {
+		t.Fatalf("evicted %v, want [a]", evicted)
+	}
+	if keys := l.Keys(); !slices.Equal(keys, []string{"d", "e", "b", "c"}) {
+		t.Fatalf("keys = %v, want [d e b c] in eviction order", keys)
+	}
+}
+
+func TestNewSLRUInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		size  int
+		ratio float64
+	}{{0, 0.5}, {10, -0.1}, {10, 1}} {
+		if _, err := NewSLRU[int, int](tt.size, tt.ratio, nil); err == nil {
+			t.Errorf("NewSLRU(%d, %v) returned no error", tt.size, tt.ratio)
+		}
+	}
+}
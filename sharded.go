@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"hash/maphash"
+	"lru/basic_lru"
+	"time"
+)
+
+// ShardedCache has to be a drop-in replacement for basic_lru.LRU.
+var _ basic_lru.LRUCache[int, int] = (*ShardedCache[int, int])(nil)
+
+// ShardedCache is a thread-safe fixed size LRU cache split into shards by the hash of the keys,
+// each with its own lock, so that operations on keys of different shards don't contend.
+// Recency of usage is tracked per shard, so the entry evicted from a full shard is the oldest
+// of that shard, not necessarily the oldest of the whole cache.
+type ShardedCache[K comparable, V any] struct {
+	shards []*Cache[K, V]
+	hash   func(key K) uint64
+}
+
+// NewSharded creates a cache of the given total size split into the given number of shards,
+// the size of which differ by at most one. The shard of a key is picked by hash, which has to
+// return the same value for equal keys. A nil hash hashes the keys formatted with fmt.Sprint,
+// which works for any key but is much slower than a hash specific to the key type.
+func NewSharded[K comparable, V any](size, shards int, hash func(key K) uint64, onEvict func(key K, value V)) (*ShardedCache[K, V], error) {
+	if shards <= 0 {
+		return nil, fmt.Errorf("invalid number of shards (%d), must be bigger than zero", shards)
+	}
+	if size < shards {
+		return nil, fmt.Errorf("invalid cache size (%d), must not be smaller than the number of shards (%d)", size, shards)
+	}
+	s := &ShardedCache[K, V]{
+		shards: make([]*Cache[K, V], shards),
+		hash:   hash,
+	}
+	if s.hash == nil {
+		seed := maphash.MakeSeed()
+		s.hash = func(key K) uint64 {
+			return maphash.String(seed, fmt.Sprint(key))
+		}
+	}
+	for i := range s.shards {
+		shard, err := NewWithOnEvict[K, V](shardSize(size, shards, i), onEvict)
+		if err != nil {
+			return nil, err
+		}
+		s.shards[i] = shard
+	}
+	return s, nil
+}
+
+// shardSize returns the size of the i-th of the shards splitting size, the first ones getting the remainder.
+func shardSize(size, shards, i int) int {
+	n := size / shards
+	if i < size%shards {
+		n++
+	}
+	return n
+}
+
+// shard returns the shard of the key.
+func (s *ShardedCache[K, V]) shard(key K) *Cache[K, V] {
+	return s.shards[s.hash(key)%uint64(len(s.shards))]
+}
+
+// Add adds an entry to the cache, returns true if an eviction occurred and
+// updates the recency of usage of the key.
+func (s *ShardedCache[K, V]) Add(key K, value V) (evicted bool) {
+	return s.shard(key).Add(key, value)
+}
+
+// Get returns key's value from the cache and updates the recency of usage of the key.
+// ok specifies if the key was found or not.
+func (s *ShardedCache[K, V]) Get(key K) (value V, ok bool) {
+	return s.shard(key).Get(key)
+}
+
+// Contains checks if a key exists in the cache without updating the recency of usage.
+func (s *ShardedCache[K, V]) Contains(key K) (ok bool) {
+	return s.shard(key).Contains(key)
+}
+
+// Peek returns key's value without updating the recency of usage of the key.
+// ok specifies if the key was found or not.
+func (s *ShardedCache[K, V]) Peek(key K) (value V, ok bool) {
+	return s.shard(key).Peek(key)
+}
+
+// Remove removes an entry from the cache with the key specified.
+// ok specifies if the key was found or not.
+func (s *ShardedCache[K, V]) Remove(key K) (ok bool) {
+	return s.shard(key).Remove(key)
+}
+
+// RemoveOldest removes the oldest entry of the shard whose oldest entry was read or added the longest ago.
+// The shards are checked one after another, so concurrent changes can make it pick a newer entry.
+func (s *ShardedCache[K, V]) RemoveOldest() (key K, value V, ok bool) {
+	if shard := s.oldestShard(); shard != nil {
+		return shard.RemoveOldest()
+	}
+	return key, value, false
+}
+
+// GetOldest returns the oldest entry of the shard whose oldest entry was read or added the longest ago.
+// The shards are checked one after another, so concurrent changes can make it pick a newer entry.
+func (s *ShardedCache[K, V]) GetOldest() (key K, value V, ok bool) {
+	if shard := s.oldestShard(); shard != nil {
+		return shard.GetOldest()
+	}
+	return key, value, false
+}
+
+// oldestShard returns the shard whose oldest entry was read or added the longest ago, nil if all are empty.
+func (s *ShardedCache[K, V]) oldestShard() *Cache[K, V] {
+	var (
+		oldest       *Cache[K, V]
+		oldestAccess time.Time
+	)
+	for _, shard := range s.shards {
+		shard.lock.RLock()
+		_, _, lastAccess, ok := shard.lru.GetOldestWithMeta()
+		shard.lock.RUnlock()
+		if ok && (oldest == nil || lastAccess.Before(oldestAccess)) {
+			oldest, oldestAccess = shard, lastAccess
+		}
+	}
+	return oldest
+}
+
+// Keys returns a slice of the keys in the cache, from oldest to newest within each shard, shard after shard.
+func (s *ShardedCache[K, V]) Keys() []K {
+	var keys []K
+	for _, shard := range s.shards {
+		keys = append(keys, shard.Keys()...)
+	}
+	return keys
+}
+
+// Values returns a slice of the values in the cache, from oldest to newest within each shard, shard after shard.
+func (s *ShardedCache[K, V]) Values() []V {
+	var values []V
+	for _, shard := range s.shards {
+		values = append(values, shard.Values()...)
+	}
+	return values
+}
+
+// Len returns the number of entries in the cache.
+// The shards are counted one after another, so the result may miss concurrent changes.
+func (s *ShardedCache[K, V]) Len() int {
+	length := 0
+	for _, shard := range s.shards {
+		length += shard.Len()
+	}
+	return length
+}
+
+// Cap returns the capacity of the cache.
+func (s *ShardedCache[K, V]) Cap() int {
+	capacity := 0
+	for _, shard := range s.shards {
+		capacity += shard.Cap()
+	}
+	return capacity
+}
+
+// Purge clears all the cache entries, shard after shard.
+func (s *ShardedCache[K, V]) Purge() {
+	for _, shard := range s.shards {
+		shard.Purge()
+	}
+}
+
+// Resize changes the cache size, splitting it across the shards like NewSharded,
+// returning number of evicted entries. Each shard keeps at least one entry.
+func (s *ShardedCache[K, V]) Resize(size int) (evicted int) {
+	for i, shard := range s.shards {
+		evicted += shard.Resize(max(shardSize(size, len(s.shards), i), 1))
+	}
+	return evicted
+}
+
+// ShardStat holds the metrics of a shard, as returned by ShardStats.
+type ShardStat struct {
+	Index  int
+	Len    int
+	Hits   uint64
+	Misses uint64
+}
+
+// ShardStats returns the metrics of each shard, by shard index. Uneven numbers point to hot keys
+// or too few shards. The shards are read one after another, so the result may miss concurrent changes.
+func (s *ShardedCache[K, V]) ShardStats() []ShardStat {
+	stats := make([]ShardStat, len(s.shards))
+	for i, shard := range s.shards {
+		st := shard.Stats()
+		stats[i] = ShardStat{Index: i, Len: shard.Len(), Hits: st.Hits, Misses: st.Misses}
+	}
+	return stats
+}
+
+// ShardImbalance returns the lookups of the busiest shard divided by the mean lookups per shard,
+// 1 meaning an even load and the number of shards meaning all the lookups hit one shard.
+// It is 1 before any lookup.
+func (s *ShardedCache[K, V]) ShardImbalance() float64 {
+	var total, busiest uint64
+	for _, st := range s.ShardStats() {
+		lookups := st.Hits + st.Misses
+		total += lookups
+		busiest = max(busiest, lookups)
+	}
+	if total == 0 {
+		return 1
+	}
+	return float64(busiest) * float64(len(s.shards)) / float64(total)
+}
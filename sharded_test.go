@@ -0,0 +1,132 @@
+package main
+
+import "testing"
+
+func TestShardStats(t *testing.T) {
+	s, err := NewSharded[int, int](64, 4, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 32; i++ {
+		s.Add(i, i)
+	}
+	if imbalance := s.ShardImbalance(); imbalance != 1 {
+		t.Fatalf("ShardImbalance before any lookup = %v, want 1", imbalance)
+	}
+
+	// one lookup of every key, then the hot key dominates
+	for i := 0; i < 32; i++ {
+		s.Get(i)
+	}
+	const hot = 7
+	for i := 0; i < 1000; i++ {
+		s.Get(hot)
+	}
+	s.Get(-1)
+
+	hotIndex := -1
+	for i, shard := range s.shards {
+		if shard == s.shard(hot) {
+			hotIndex = i
+		}
+	}
+	stats := s.ShardStats()
+	if len(stats) != 4 {
+		t.Fatalf("got %d shard stats, want 4", len(stats))
+	}
+	var length int
+	var hits, misses uint64
+	for i, st := range stats {
+		if st.Index != i {
+			t.Fatalf("stats[%d].Index = %d", i, st.Index)
+		}
+		length += st.Len
+		hits += st.Hits
+		misses += st.Misses
+		if i != hotIndex && st.Hits >= stats[hotIndex].Hits {
+			t.Fatalf("shard %d has %d hits, not fewer than the hot shard's %d", i, st.Hits, stats[hotIndex].Hits)
+		}
+	}
+	if length != 32 || hits != 1032 || misses != 1 {
+		t.Fatalf("summed stats: len %d, hits %d, misses %d, want 32, 1032, 1", length, hits, misses)
+	}
+	// the hot shard holds at least 1000 of the 1033 lookups
+	if imbalance := s.ShardImbalance(); imbalance < 4*1000.0/1033 {
+		t.Fatalf("ShardImbalance = %v, want at least %v", imbalance, 4*1000.0/1033)
+	}
+}
+
+// hashInt hashes the int keys to themselves.
+func hashInt(key int) uint64 {
+	return uint64(key)
+}
+
+func TestShardedLen(t *testing.T) {
+	s, err := NewSharded[int, int](100, 8, hashInt, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Cap() != 100 {
+		t.Fatalf("Cap = %d, want 100", s.Cap())
+	}
+	// the keys are spread evenly by the hash, so no shard overflows
+	for i := 0; i < 60; i++ {
+		s.Add(i, i)
+		if s.shard(i) != s.shards[i%8] {
+			t.Fatalf("key %d is not in shard %d", i, i%8)
+		}
+	}
+	if s.Len() != 60 || len(s.Keys()) != 60 || len(s.Values()) != 60 {
+		t.Fatalf("Len = %d with %d keys and %d values, want 60", s.Len(), len(s.Keys()), len(s.Values()))
+	}
+	// every shard is full after many more inserts, whatever the spread of the keys
+	for i := 60; i < 10000; i++ {
+		s.Add(i, i)
+	}
+	if s.Len() != 100 || len(s.Keys()) != 100 {
+		t.Fatalf("Len = %d with %d keys, want 100", s.Len(), len(s.Keys()))
+	}
+	s.Purge()
+	if s.Len() != 0 {
+		t.Fatalf("Len after Purge = %d, want 0", s.Len())
+	}
+}
+
+// parallelCache is the part of the caches compared by BenchmarkShardedParallel.
+type parallelCache interface {
+	Add(key, value int) bool
+	Get(key int) (int, bool)
+}
+
+func BenchmarkShardedParallel(b *testing.B) {
+	const size = 1024
+	c, err := New[int, int](size)
+	if err != nil {
+		b.Fatal(err)
+	}
+	s, err := NewSharded[int, int](size, 16, hashInt, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, bc := range []struct {
+		name  string
+		cache parallelCache
+	}{
+		{"Cache", c},
+		{"ShardedCache", s},
+	} {
+		// one Add for every three Gets, of twice as many keys as fit
+		b.Run(bc.name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					key := i * 7919 % (2 * size)
+					if i%4 == 0 {
+						bc.cache.Add(key, i)
+					} else {
+						bc.cache.Get(key)
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package simulate
+
+import "lru/basic_lru"
+
+// LRUHitRate replays the trace of accessed keys against an LRU cache of the given size
+// and returns the share of accesses which were hits.
+func LRUHitRate[K comparable](trace []K, size int) float64 {
+	if len(trace) == 0 || size <= 0 {
+		return 0
+	}
+	cache, _ := basic_lru.NewLRU[K, struct{}](size, nil)
+	hits := 0
+	for _, key := range trace {
+		if _, ok := cache.Get(key); ok {
+			hits++
+			continue
+		}
+		cache.Add(key, struct{}{})
+	}
+	return float64(hits) / float64(len(trace))
+}
+
+// BeladyHitRate replays the trace of accessed keys against an optimal cache of the given size,
+// which evicts the key accessed again the farthest in the future (Belady's MIN algorithm),
+// and returns the share of accesses which were hits. It is the upper bound for any eviction policy.
+//
+// Every miss scans the cached keys, so it takes O(len(trace) * size) time.
+func BeladyHitRate[K comparable](trace []K, size int) float64 {
+	if len(trace) == 0 || size <= 0 {
+		return 0
+	}
+	// nextUse[i] is the position of the next access of trace[i], len(trace) if there is none
+	nextUse := make([]int, len(trace))
+	seen := make(map[K]int)
+	for i := len(trace) - 1; i >= 0; i-- {
+		if next, ok := seen[trace[i]]; ok {
+			nextUse[i] = next
+		} else {
+			nextUse[i] = len(trace)
+		}
+		seen[trace[i]] = i
+	}
+
+	// cached keys with the position of their next access
+	cache := make(map[K]int, size)
+	hits := 0
+	for i, key := range trace {
+		if _, ok := cache[key]; ok {
+			hits++
+			cache[key] = nextUse[i]
+			continue
+		}
+		if len(cache) >= size {
+			var victim K
+			farthest := -1
+			for k, next := range cache {
+				if next > farthest {
+					victim, farthest = k, next
+				}
+			}
+			delete(cache, victim)
+		}
+		cache[key] = nextUse[i]
+	}
+	return float64(hits) / float64(len(trace))
+}
@@ -0,0 +1,32 @@
+package simulate
+
+import "testing"
+
+func TestHitRates(t *testing.T) {
+	// the classic trace showing Belady's anomaly for FIFO
+	trace := []int{1, 2, 3, 4, 1, 2, 5, 1, 2, 3, 4, 5}
+	tests := []struct {
+		size        int
+		lru, belady float64
+	}{
+		{size: 0, lru: 0, belady: 0},
+		{size: 1, lru: 0, belady: 0},
+		{size: 3, lru: 2.0 / 12, belady: 5.0 / 12},
+		{size: 4, lru: 4.0 / 12, belady: 6.0 / 12},
+		{size: 5, lru: 7.0 / 12, belady: 7.0 / 12},
+	}
+	for _, tt := range tests {
+		if got := LRUHitRate(trace, tt.size); got != tt.lru {
+			t.Errorf("LRUHitRate with size %d = %v, want %v", tt.size, got, tt.lru)
+		}
+		if got := BeladyHitRate(trace, tt.size); got != tt.belady {
+			t.Errorf("BeladyHitRate with size %d = %v, want %v", tt.size, got, tt.belady)
+		}
+	}
+	if got := LRUHitRate([]string{}, 3); got != 0 {
+		t.Errorf("LRUHitRate of an empty trace = %v, want 0", got)
+	}
+	if got := BeladyHitRate([]string{}, 3); got != 0 {
+		t.Errorf("BeladyHitRate of an empty trace = %v, want 0", got)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+// CacheStats holds the statistics of the cache captured at the same moment.
+type CacheStats struct {
+	// Hits and Misses count the lookups of present and missing keys.
+	Hits   uint64
+	Misses uint64
+	// Evictions counts the entries evicted to make room for new ones or by Resize.
+	Evictions uint64
+	Len       int
+	Cap       int
+}
+
+// Snapshot returns the statistics of the cache captured under one lock acquisition,
+// so the numbers are consistent with each other.
+func (c *Cache[K, V]) Snapshot() CacheStats {
+	c.lock.Lock()
+	stats := CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Len:       c.lru.Len(),
+		Cap:       c.lru.Cap(),
+	}
+	c.lock.Unlock()
+	return stats
+}
+
+// Stats holds the counters of the cache.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// Insertions counts the calls of Add.
+	Insertions uint64
+}
+
+// HitRatio returns the share of lookups which found the key, 0 if there were no lookups.
+func (s Stats) HitRatio() float64 {
+	lookups := s.Hits + s.Misses
+	if lookups == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(lookups)
+}
+
+// Stats returns the counters of the cache without taking the lock.
+// The counters are read one by one, use Snapshot for numbers consistent with each other.
+func (c *Cache[K, V]) Stats() Stats {
+	return Stats{
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+		Evictions:  c.evictions.Load(),
+		Insertions: c.adds.Load(),
+	}
+}
+
+// ResetStats sets all the counters of the cache to zero.
+func (c *Cache[K, V]) ResetStats() {
+	c.lock.Lock()
+	c.hits.Store(0)
+	c.misses.Store(0)
+	c.evictions.Store(0)
+	c.adds.Store(0)
+	c.addEvictions.Store(0)
+	c.window.reset()
+	c.lock.Unlock()
+}
+
+// EvictionPressure returns the share of Add calls which evicted an entry, 0 if Add wasn't called.
+// Values close to 1 mean the cache is too small for its working set.
+func (c *Cache[K, V]) EvictionPressure() float64 {
+	c.lock.Lock()
+	adds, evictions := c.adds.Load(), c.addEvictions.Load()
+	c.lock.Unlock()
+	if adds == 0 {
+		return 0
+	}
+	return float64(evictions) / float64(adds)
+}
+
+// countLookup counts a lookup of a key as a hit or a miss. Has to be called with lock!
+func (c *Cache[K, V]) countLookup(found bool) {
+	if found {
+		c.hits.Add(1)
+		c.window.record(c.now(), 1, 0)
+	} else {
+		c.misses.Add(1)
+		c.window.record(c.now(), 0, 1)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	c, err := New[int, int](3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		c.Add(i, i)
+	}
+	c.Get(4)
+	c.Get(3)
+	c.Get(0)
+	want := CacheStats{Hits: 2, Misses: 1, Evictions: 2, Len: 3, Cap: 3}
+	if got := c.Snapshot(); got != want {
+		t.Fatalf("Snapshot = %+v, want %+v", got, want)
+	}
+}
+
+func TestSnapshotConsistent(t *testing.T) {
+	const writers, perWriter = 4, 500
+	c, err := New[int, int](64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				c.Add(w*perWriter+i, i)
+				c.Get(w*perWriter + i)
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// with distinct keys only added, every added key is either present or evicted
+	var prev CacheStats
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		s := c.Snapshot()
+		if s.Len > s.Cap || (s.Evictions > 0 && s.Len != s.Cap) {
+			t.Fatalf("inconsistent snapshot %+v", s)
+		}
+		if uint64(s.Len)+s.Evictions < uint64(prev.Len)+prev.Evictions || s.Hits < prev.Hits {
+			t.Fatalf("snapshot %+v went back from %+v", s, prev)
+		}
+		prev = s
+	}
+	if s := c.Snapshot(); uint64(s.Len)+s.Evictions != writers*perWriter || s.Hits+s.Misses != writers*perWriter {
+		t.Fatalf("final snapshot %+v does not add up to %d adds and lookups", s, writers*perWriter)
+	}
+}
+
+func TestEvictionPressure(t *testing.T) {
+	c, err := New[int, int](2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := c.EvictionPressure(); p != 0 {
+		t.Fatalf("EvictionPressure before any Add = %v, want 0", p)
+	}
+	c.Add(1, 1)
+	c.Add(2, 2)
+	c.Add(1, 10) // overwrite
+	c.Add(3, 3)  // evicts 2
+	c.Add(4, 4)  // evicts 1
+	c.Add(4, 40) // overwrite
+	if p := c.EvictionPressure(); p != 2.0/6 {
+		t.Fatalf("EvictionPressure = %v, want %v", p, 2.0/6)
+	}
+}
+
+func TestStats(t *testing.T) {
+	c, err := New[string, int](2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ratio := c.Stats().HitRatio(); ratio != 0 {
+		t.Fatalf("HitRatio before any lookup = %v, want 0", ratio)
+	}
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("c", 3) // evicts a
+	c.Add("c", 4)
+	c.Get("b")
+	c.Get("a")
+	c.Peek("c")
+	c.Peek("a")
+	c.Get("c")
+
+	want := Stats{Hits: 3, Misses: 2, Evictions: 1, Insertions: 4}
+	if got := c.Stats(); got != want {
+		t.Fatalf("Stats = %+v, want %+v", got, want)
+	}
+	if ratio := c.Stats().HitRatio(); ratio != 0.6 {
+		t.Fatalf("HitRatio = %v, want 0.6", ratio)
+	}
+
+	c.ResetStats()
+	if got := c.Stats(); got != (Stats{}) {
+		t.Fatalf("Stats after ResetStats = %+v, want zero", got)
+	}
+	c.Get("b")
+	if got := c.Stats(); got != (Stats{Hits: 1}) {
+		t.Fatalf("Stats after a Get = %+v, want 1 hit", got)
+	}
+}
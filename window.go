@@ -0,0 +1,91 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// hitWindowSlots is the number of intervals the recent lookups are counted in,
+	// which bounds the window of WindowedHitRatio to one minute
+	hitWindowSlots    = 60
+	hitWindowInterval = time.Second
+)
+
+// hitWindow counts the hits and misses of the recent intervals in a ring of slots.
+// The slots are updated atomically, so concurrent lookups under a read lock don't serialize on it.
+type hitWindow struct {
+	slots [hitWindowSlots]hitSlot
+}
+
+// hitSlot holds the counts of one interval, the number of which since the Unix epoch it stores.
+type hitSlot struct {
+	interval atomic.Int64
+	hits     atomic.Uint64
+	misses   atomic.Uint64
+}
+
+// record counts the lookups in the interval of now, reusing the slot of an interval out of the ring.
+// The lookups counted concurrently with the reuse of a slot may be lost, which the ratio tolerates.
+func (w *hitWindow) record(now time.Time, hits, misses uint64) {
+	interval := now.UnixNano() / int64(hitWindowInterval)
+	slot := &w.slots[interval%hitWindowSlots]
+	for {
+		current := slot.interval.Load()
+		if current == interval {
+			break
+		}
+		if current > interval {
+			// the interval is already out of the ring
+			return
+		}
+		if slot.interval.CompareAndSwap(current, interval) {
+			slot.hits.Store(0)
+			slot.misses.Store(0)
+			break
+		}
+	}
+	if hits > 0 {
+		slot.hits.Add(hits)
+	}
+	if misses > 0 {
+		slot.misses.Add(misses)
+	}
+}
+
+// ratio returns the hit ratio of the intervals overlapping the window ending at now.
+func (w *hitWindow) ratio(now time.Time, window time.Duration) float64 {
+	last := now.UnixNano() / int64(hitWindowInterval)
+	first := last - int64((window+hitWindowInterval-1)/hitWindowInterval) + 1
+	if first <= last-hitWindowSlots {
+		first = last - hitWindowSlots + 1
+	}
+	var hits, misses uint64
+	for i := range w.slots {
+		slot := &w.slots[i]
+		if interval := slot.interval.Load(); interval >= first && interval <= last {
+			hits += slot.hits.Load()
+			misses += slot.misses.Load()
+		}
+	}
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// reset clears the counts of all the intervals.
+func (w *hitWindow) reset() {
+	for i := range w.slots {
+		w.slots[i].interval.Store(0)
+		w.slots[i].hits.Store(0)
+		w.slots[i].misses.Store(0)
+	}
+}
+
+// WindowedHitRatio returns the share of lookups which found the key within the recent window,
+// 0 if there were no lookups. Lookups are counted per second, so the window is rounded up
+// to whole seconds, and only the last minute is kept, so longer windows are cut to it.
+func (c *Cache[K, V]) WindowedHitRatio(window time.Duration) float64 {
+	return c.window.ratio(c.now(), window)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWindowedHitRatio(t *testing.T) {
+	c, _ := New[int, int](8)
+	clock := newFakeClock()
+	c.clock = clock
+	c.Add(1, 1)
+
+	// misses long before the window
+	for i := range 6 {
+		c.Get(100 + i)
+	}
+	clock.Advance(30 * time.Second)
+	// one miss and three hits in consecutive intervals of the window
+	c.Get(100)
+	for range 3 {
+		clock.Advance(time.Second)
+		c.Get(1)
+	}
+
+	if got, want := c.Stats().HitRatio(), 0.3; got != want {
+		t.Fatalf("HitRatio() = %v, want %v", got, want)
+	}
+	if got, want := c.WindowedHitRatio(10*time.Second), 0.75; got != want {
+		t.Fatalf("WindowedHitRatio(10s) = %v, want %v", got, want)
+	}
+	if got, want := c.WindowedHitRatio(2*time.Second), 1.0; got != want {
+		t.Fatalf("WindowedHitRatio(2s) = %v, want %v", got, want)
+	}
+	if got, want := c.WindowedHitRatio(time.Minute), 0.3; got != want {
+		t.Fatalf("WindowedHitRatio(1m) = %v, want %v", got, want)
+	}
+
+	// the whole window moved past the lookups
+	clock.Advance(2 * time.Minute)
+	if got := c.WindowedHitRatio(time.Minute); got != 0 {
+		t.Fatalf("WindowedHitRatio(1m) after the window = %v, want 0", got)
+	}
+}
+
+func TestWindowedHitRatioConcurrent(t *testing.T) {
+	const readers, perReader = 8, 1000
+	c, _ := New[int, int](8)
+	c.clock = newFakeClock()
+	c.Add(1, 1)
+
+	// lookups under the read lock of Peek count into the same interval without losing any
+	var wg sync.WaitGroup
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perReader; i++ {
+				if r%2 == 0 {
+					c.Peek(1)
+				} else {
+					c.Peek(2)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got, want := c.WindowedHitRatio(time.Second), 0.5; got != want {
+		t.Fatalf("WindowedHitRatio(1s) = %v, want %v", got, want)
+	}
+}